@@ -0,0 +1,706 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+	"go-drone-deploy/internal/conf"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/config/file"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// overlayPath 返回环境覆盖配置的路径：config.yaml 对应 config.<env>.yaml
+func overlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// loadDeployConfig 加载配置文件及环境覆盖配置，合并 Drone 变量，返回部署配置及已加载的文件。
+// env 为空时使用基础配置中的 env，均未设置时为 dev
+func loadDeployConfig(path, env string) (*biz.DeployConfig, []string, error) {
+	files := []string{path}
+	if env == "" {
+		base, err := loadConfig(files)
+		if err != nil {
+			return nil, nil, err
+		}
+		env = base.GetDeploy().GetEnv()
+	}
+	if env == "" {
+		env = "dev"
+	}
+
+	overlay := overlayPath(path, env)
+	if _, err := os.Stat(overlay); err == nil {
+		files = append(files, overlay)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("读取环境配置 %s 失败: %w", overlay, err)
+	}
+
+	bc, err := loadConfig(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bc.Deploy == nil {
+		return nil, nil, fmt.Errorf("配置 %s 缺少 deploy", path)
+	}
+	config, err := deployConfig(bc.Deploy, env)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+	return config, files, nil
+}
+
+// loadConfig 按顺序合并配置文件：后加载的文件覆盖同名字段，列表整体替换。未知的配置项视为错误
+func loadConfig(files []string) (*conf.Bootstrap, error) {
+	var sources []config.Source
+	for _, f := range files {
+		sources = append(sources, file.NewSource(f))
+	}
+	c := config.New(config.WithSource(sources...))
+	defer c.Close()
+
+	if err := c.Load(); err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+	var raw json.RawMessage
+	if err := c.Scan(&raw); err != nil {
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+	var bc conf.Bootstrap
+	if err := decodeConfig(raw, &bc); err != nil {
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+	return &bc, nil
+}
+
+// decodeConfig 将合并后的配置解码为 protobuf 消息，不忽略未知字段。
+// YAML 中未加引号的数字和布尔值（如 max_surge: 1、pods: 20）写入字符串字段时按字符串处理
+func decodeConfig(data []byte, m proto.Message) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	coerceMessage(value, m.ProtoReflect().Descriptor())
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(data, m)
+}
+
+// coerceMessage 将消息中字符串字段的数字和布尔值转换为字符串，未知字段保持不变，由解码时报错
+func coerceMessage(value any, md protoreflect.MessageDescriptor) {
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return
+	}
+	fields := md.Fields()
+	for key, v := range obj {
+		fd := fields.ByJSONName(key)
+		if fd == nil {
+			fd = fields.ByTextName(key)
+		}
+		if fd == nil {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			if m, ok := v.(map[string]any); ok {
+				for k, item := range m {
+					m[k] = coerceValue(item, fd.MapValue())
+				}
+			}
+		case fd.IsList():
+			if list, ok := v.([]any); ok {
+				for i, item := range list {
+					list[i] = coerceValue(item, fd)
+				}
+			}
+		default:
+			obj[key] = coerceValue(v, fd)
+		}
+	}
+}
+
+// coerceValue 转换列表元素、映射值或单个字段的值
+func coerceValue(value any, fd protoreflect.FieldDescriptor) any {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		switch v := value.(type) {
+		case json.Number:
+			return v.String()
+		case bool:
+			return strconv.FormatBool(v)
+		}
+	case protoreflect.MessageKind:
+		coerceMessage(value, fd.Message())
+	}
+	return value
+}
+
+// parseDuration 解析时间间隔配置，如 30s、5m，为空时为零
+func parseDuration(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s 不是合法的时间间隔: %s", name, value)
+	}
+	return d, nil
+}
+
+// deployConfig 将配置文件中的部署配置转换为业务层配置，env 为解析后的部署环境
+func deployConfig(c *conf.Deploy, env string) (*biz.DeployConfig, error) {
+	config := &biz.DeployConfig{
+		ProjectName: c.GetProjectName(),
+		Author:      c.GetAuthor(),
+		Namespace:   c.GetNamespace(),
+		Version:     c.GetVersion(),
+		Env:         env,
+		Commit:      os.Getenv("DRONE_COMMIT_SHA"),
+		BuildLink:   os.Getenv("DRONE_BUILD_LINK"),
+		Branch:      os.Getenv("DRONE_BRANCH"),
+	}
+
+	if d := c.GetDocker(); d != nil {
+		config.Docker = &biz.DockerConfig{
+			Registry:       d.GetRegistry(),
+			Username:       d.GetUsername(),
+			Password:       d.GetPassword(),
+			ImageName:      d.GetImageName(),
+			DockerfilePath: d.GetDockerfilePath(),
+			BuildContext:   d.GetBuildContext(),
+		}
+		if sc := d.GetScan(); sc != nil {
+			config.Docker.Scan = &biz.ImageScan{Severity: sc.GetSeverity(), IgnoreUnfixed: sc.GetIgnoreUnfixed()}
+		}
+		if sg := d.GetSign(); sg != nil {
+			config.Docker.Sign = &biz.ImageSign{Key: sg.GetKey()}
+		}
+	}
+
+	if k := c.GetK8S(); k != nil {
+		k8s, err := k8sConfig(k, config.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		config.K8s = k8s
+	}
+
+	if n := c.GetNotify(); n != nil {
+		notify, err := notifyConfig(n)
+		if err != nil {
+			return nil, err
+		}
+		config.Notify = notify
+	}
+
+	for name, p := range c.GetPipelines() {
+		pipeline, err := pipelineConfig(name, p)
+		if err != nil {
+			return nil, err
+		}
+		if config.Pipelines == nil {
+			config.Pipelines = map[biz.DeployFlow]*biz.Pipeline{}
+		}
+		config.Pipelines[biz.DeployFlow(name)] = pipeline
+	}
+
+	for name, sp := range c.GetStepPolicies() {
+		policy := &biz.StepPolicy{}
+		var err error
+		if policy.Timeout, err = parseDuration("step_policies."+name+".timeout", sp.GetTimeout()); err != nil {
+			return nil, err
+		}
+		if policy.Retry, err = stepRetry("step_policies."+name+".retry", sp.GetRetry()); err != nil {
+			return nil, err
+		}
+		if config.StepPolicies == nil {
+			config.StepPolicies = map[string]*biz.StepPolicy{}
+		}
+		config.StepPolicies[name] = policy
+	}
+
+	return config, nil
+}
+
+// k8sConfig 转换 Kubernetes 配置，未设置命名空间时使用部署配置中的 namespace
+func k8sConfig(k *conf.Kubernetes, defaultNamespace string) (*biz.K8sConfig, error) {
+	var err error
+	namespace := k.GetNamespace()
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	config := &biz.K8sConfig{
+		KubeconfigPath:        k.GetKubeconfigPath(),
+		Kubeconfig:            k.GetKubeconfig(),
+		Context:               k.GetContext(),
+		InCluster:             k.GetInCluster(),
+		Server:                k.GetServer(),
+		Token:                 k.GetToken(),
+		TokenFile:             k.GetTokenFile(),
+		CAFile:                k.GetCaFile(),
+		CAData:                k.GetCaData(),
+		InsecureSkipTLSVerify: k.GetInsecureSkipTlsVerify(),
+		QPS:                   k.GetQps(),
+		Burst:                 int(k.GetBurst()),
+		Namespace:             namespace,
+		DeploymentName:        k.GetDeploymentName(),
+		ServiceName:           k.GetServiceName(),
+		Image:                 k.GetImage(),
+		Version:               k.GetVersion(),
+		Replicas:              k.GetReplicas(),
+		Resources:             resources(k.GetResources()),
+		Ports:                 ports(k.GetPorts()),
+		EnvVars:               envVars(k.GetEnvVars()),
+		VolumeMounts:          volumeMounts(k.GetVolumeMounts()),
+		InitContainers:        containers(k.GetInitContainers()),
+		Sidecars:              containers(k.GetSidecars()),
+	}
+	if config.RequestTimeout, err = parseDuration("request_timeout", k.GetRequestTimeout()); err != nil {
+		return nil, err
+	}
+
+	if a := k.GetAutoscaling(); a != nil {
+		config.Autoscaling = &biz.Autoscaling{
+			MinReplicas:                       a.GetMinReplicas(),
+			MaxReplicas:                       a.GetMaxReplicas(),
+			TargetCPUUtilizationPercentage:    a.GetTargetCpuUtilizationPercentage(),
+			TargetMemoryUtilizationPercentage: a.GetTargetMemoryUtilizationPercentage(),
+		}
+	}
+	if d := k.GetDisruption(); d != nil {
+		config.Disruption = &biz.Disruption{
+			MinAvailable:   d.GetMinAvailable(),
+			MaxUnavailable: d.GetMaxUnavailable(),
+		}
+	}
+
+	for _, v := range k.GetVolumes() {
+		volume := &biz.Volume{
+			Name:                  v.GetName(),
+			ConfigMap:             v.GetConfigMap(),
+			Secret:                v.GetSecret(),
+			PersistentVolumeClaim: v.GetPersistentVolumeClaim(),
+			ReadOnly:              v.GetReadOnly(),
+		}
+		if e := v.GetEmptyDir(); e != nil {
+			volume.EmptyDir = &biz.EmptyDir{Medium: e.GetMedium(), SizeLimit: e.GetSizeLimit()}
+		}
+		config.Volumes = append(config.Volumes, volume)
+	}
+
+	if st := k.GetStrategy(); st != nil {
+		config.Strategy = &biz.RolloutStrategy{MaxSurge: st.GetMaxSurge(), MaxUnavailable: st.GetMaxUnavailable()}
+	}
+	config.NodeSelector = k.GetNodeSelector()
+	for _, t := range k.GetTolerations() {
+		config.Tolerations = append(config.Tolerations, &biz.Toleration{
+			Key:               t.GetKey(),
+			Operator:          t.GetOperator(),
+			Value:             t.GetValue(),
+			Effect:            t.GetEffect(),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	if a := k.GetAffinity(); a != nil {
+		config.Affinity = &biz.Affinity{PodAntiAffinity: a.GetPodAntiAffinity()}
+		for key, values := range a.GetRequiredNodeLabels() {
+			if config.Affinity.RequiredNodeLabels == nil {
+				config.Affinity.RequiredNodeLabels = map[string][]string{}
+			}
+			config.Affinity.RequiredNodeLabels[key] = stringList(values)
+		}
+	}
+	for _, ts := range k.GetTopologySpreadConstraints() {
+		config.TopologySpreadConstraints = append(config.TopologySpreadConstraints, &biz.TopologySpread{
+			TopologyKey:       ts.GetTopologyKey(),
+			MaxSkew:           ts.GetMaxSkew(),
+			WhenUnsatisfiable: ts.GetWhenUnsatisfiable(),
+		})
+	}
+
+	config.ServiceAccountName = k.GetServiceAccountName()
+	config.ImagePullSecrets = k.GetImagePullSecrets()
+	if sc := k.GetSecurityContext(); sc != nil {
+		config.SecurityContext = &biz.SecurityContext{
+			RunAsNonRoot:             sc.RunAsNonRoot,
+			RunAsUser:                sc.RunAsUser,
+			RunAsGroup:               sc.RunAsGroup,
+			FSGroup:                  sc.FsGroup,
+			ReadOnlyRootFilesystem:   sc.ReadOnlyRootFilesystem,
+			AllowPrivilegeEscalation: sc.AllowPrivilegeEscalation,
+			DropCapabilities:         sc.GetDropCapabilities(),
+			AddCapabilities:          sc.GetAddCapabilities(),
+			SeccompProfile:           sc.GetSeccompProfile(),
+		}
+	}
+
+	config.AppName = k.GetAppName()
+	config.Component = k.GetComponent()
+	config.PartOf = k.GetPartOf()
+	config.Labels = k.GetLabels()
+	config.Annotations = k.GetAnnotations()
+	config.PodAnnotations = k.GetPodAnnotations()
+
+	if m := k.GetManifests(); m != nil {
+		config.Manifests = &biz.Manifests{
+			Paths:         m.GetPaths(),
+			Kustomization: m.GetKustomization(),
+			Template:      m.GetTemplate(),
+			Vars:          m.GetVars(),
+		}
+	}
+
+	config.Release = k.GetRelease()
+	config.Prune = k.GetPrune()
+
+	for _, h := range k.GetHooks() {
+		timeout, err := parseDuration("hooks."+h.GetName()+".timeout", h.GetTimeout())
+		if err != nil {
+			return nil, err
+		}
+		config.Hooks = append(config.Hooks, &biz.Hook{
+			Name:         h.GetName(),
+			Phase:        biz.HookPhase(h.GetPhase()),
+			Command:      h.GetCommand(),
+			Args:         h.GetArgs(),
+			EnvVars:      envVars(h.GetEnvVars()),
+			Timeout:      timeout,
+			BackoffLimit: h.GetBackoffLimit(),
+		})
+	}
+
+	if b := k.GetBootstrap(); b != nil {
+		config.Bootstrap = &biz.NamespaceBootstrap{
+			Labels:          b.GetLabels(),
+			Annotations:     b.GetAnnotations(),
+			PodSecurity:     b.GetPodSecurity(),
+			ResourceQuota:   b.GetResourceQuota(),
+			ImagePullSecret: b.GetImagePullSecret(),
+			Manage:          b.GetManage(),
+		}
+		if lr := b.GetLimitRange(); lr != nil {
+			config.Bootstrap.LimitRange = &biz.LimitRange{
+				Default:        lr.GetDefault(),
+				DefaultRequest: lr.GetDefaultRequest(),
+				Max:            lr.GetMax(),
+				Min:            lr.GetMin(),
+			}
+		}
+	}
+
+	for _, t := range k.GetTargets() {
+		config.Targets = append(config.Targets, &biz.Target{
+			Name:                  t.GetName(),
+			KubeconfigPath:        t.GetKubeconfigPath(),
+			Kubeconfig:            t.GetKubeconfig(),
+			Context:               t.GetContext(),
+			Server:                t.GetServer(),
+			Token:                 t.GetToken(),
+			TokenFile:             t.GetTokenFile(),
+			CAFile:                t.GetCaFile(),
+			CAData:                t.GetCaData(),
+			InsecureSkipTLSVerify: t.GetInsecureSkipTlsVerify(),
+			Namespace:             t.GetNamespace(),
+		})
+	}
+	config.Parallel = k.GetParallel()
+	switch policy := biz.FailurePolicy(k.GetFailurePolicy()); policy {
+	case "", biz.FailureStop, biz.FailureContinue, biz.FailureRollback:
+		config.FailurePolicy = policy
+	default:
+		return nil, fmt.Errorf("不支持的失败处理策略: %s", policy)
+	}
+
+	if config.RolloutTimeout, err = parseDuration("rollout_timeout", k.GetRolloutTimeout()); err != nil {
+		return nil, err
+	}
+	config.DiagnosticLogLines = k.GetDiagnosticLogLines()
+
+	return config, nil
+}
+
+// pipelineConfig 转换自定义流水线
+func pipelineConfig(name string, p *conf.Pipeline) (*biz.Pipeline, error) {
+	pipeline := &biz.Pipeline{Quiet: p.GetQuiet()}
+	for _, s := range p.GetSteps() {
+		step := &biz.PipelineStep{
+			Name:            s.GetName(),
+			Uses:            s.GetUses(),
+			Needs:           s.GetNeeds(),
+			ContinueOnError: s.GetContinueOnError(),
+			With:            s.GetWith(),
+		}
+		if w := s.GetWhen(); w != nil {
+			step.When = &biz.StepCondition{Env: w.GetEnv(), Branch: w.GetBranch()}
+		}
+		prefix := "pipelines." + name + "." + s.GetName()
+		var err error
+		if step.Timeout, err = parseDuration(prefix+".timeout", s.GetTimeout()); err != nil {
+			return nil, err
+		}
+		if step.Retry, err = stepRetry(prefix+".retry", s.GetRetry()); err != nil {
+			return nil, err
+		}
+		pipeline.Steps = append(pipeline.Steps, step)
+	}
+	return pipeline, nil
+}
+
+// stepRetry 转换步骤重试策略，不支持的错误类别返回错误
+func stepRetry(name string, r *conf.StepRetry) (*biz.StepRetry, error) {
+	if r == nil {
+		return nil, nil
+	}
+	retry := &biz.StepRetry{MaxAttempts: int(r.GetMaxAttempts())}
+	var err error
+	if retry.InitialBackoff, err = parseDuration(name+".initial_backoff", r.GetInitialBackoff()); err != nil {
+		return nil, err
+	}
+	if retry.MaxBackoff, err = parseDuration(name+".max_backoff", r.GetMaxBackoff()); err != nil {
+		return nil, err
+	}
+	for _, on := range r.GetOn() {
+		switch class := biz.ErrorClass(on); class {
+		case biz.ErrorTimeout, biz.ErrorNetwork, biz.ErrorThrottled, biz.ErrorServer, biz.ErrorConflict, biz.ErrorExec, biz.ErrorAny:
+			retry.On = append(retry.On, class)
+		default:
+			return nil, fmt.Errorf("%s.on 包含不支持的错误类别: %s", name, on)
+		}
+	}
+	return retry, nil
+}
+
+// notifyConfig 转换通知配置
+func notifyConfig(n *conf.Notify) (*biz.NotifyConfig, error) {
+	config := &biz.NotifyConfig{
+		Enabled:    n.GetEnabled(),
+		Type:       biz.NotifierType(n.GetType()),
+		WebhookURL: n.GetWebhookUrl(),
+		Channel:    n.GetChannel(),
+		Secret:     n.GetSecret(),
+	}
+	var err error
+	if config.Events, err = deployEvents("notify.events", n.GetEvents()); err != nil {
+		return nil, err
+	}
+	if config.Format, err = messageFormat("notify.format", n.GetFormat()); err != nil {
+		return nil, err
+	}
+	config.Language = n.GetLanguage()
+	for key, tmpl := range n.GetTemplates() {
+		events, err := deployEvents("notify.templates", []string{key})
+		if err != nil {
+			return nil, err
+		}
+		if config.Templates == nil {
+			config.Templates = map[biz.DeployEvent]*biz.MessageTemplate{}
+		}
+		config.Templates[events[0]] = &biz.MessageTemplate{Title: tmpl.GetTitle(), Text: tmpl.GetText()}
+	}
+
+	if config.Timeout, err = parseDuration("notify.timeout", n.GetTimeout()); err != nil {
+		return nil, err
+	}
+	if r := n.GetRetry(); r != nil {
+		config.Retry = &biz.NotifyRetry{MaxAttempts: int(r.GetMaxAttempts())}
+		if config.Retry.InitialBackoff, err = parseDuration("notify.retry.initial_backoff", r.GetInitialBackoff()); err != nil {
+			return nil, err
+		}
+		if config.Retry.MaxBackoff, err = parseDuration("notify.retry.max_backoff", r.GetMaxBackoff()); err != nil {
+			return nil, err
+		}
+	}
+	config.OutboxDir = n.GetOutboxDir()
+	if config.OutboxMaxAge, err = parseDuration("notify.outbox_max_age", n.GetOutboxMaxAge()); err != nil {
+		return nil, err
+	}
+	if config.OutboxFlushTimeout, err = parseDuration("notify.outbox_flush_timeout", n.GetOutboxFlushTimeout()); err != nil {
+		return nil, err
+	}
+
+	for i, w := range n.GetWebhooks() {
+		events, err := deployEvents(fmt.Sprintf("notify.webhooks[%d].events", i), w.GetEvents())
+		if err != nil {
+			return nil, err
+		}
+		format := biz.WebhookFormat(w.GetFormat())
+		switch format {
+		case "", biz.WebhookJSON, biz.WebhookCloudEvents:
+		default:
+			return nil, fmt.Errorf("notify.webhooks[%d].format 不支持的 Webhook 格式: %s", i, format)
+		}
+		config.Webhooks = append(config.Webhooks, &biz.WebhookConfig{
+			Name:    w.GetName(),
+			URL:     w.GetUrl(),
+			Secret:  w.GetSecret(),
+			Events:  events,
+			Format:  format,
+			Headers: w.GetHeaders(),
+		})
+	}
+	config.EventDir = n.GetEventDir()
+
+	for i, nc := range n.GetNotifiers() {
+		events, err := deployEvents(fmt.Sprintf("notify.notifiers[%d].events", i), nc.GetEvents())
+		if err != nil {
+			return nil, err
+		}
+		format, err := messageFormat(fmt.Sprintf("notify.notifiers[%d].format", i), nc.GetFormat())
+		if err != nil {
+			return nil, err
+		}
+		notifier := &biz.NotifierConfig{
+			Name:       nc.GetName(),
+			Type:       biz.NotifierType(nc.GetType()),
+			WebhookURL: nc.GetWebhookUrl(),
+			Channel:    nc.GetChannel(),
+			Secret:     nc.GetSecret(),
+			Events:     events,
+			Format:     format,
+		}
+		if sc := nc.GetSmtp(); sc != nil {
+			notifier.SMTP = &biz.SMTPConfig{
+				Host:               sc.GetHost(),
+				Port:               int(sc.GetPort()),
+				Security:           biz.SMTPSecurity(sc.GetSecurity()),
+				InsecureSkipVerify: sc.GetInsecureSkipVerify(),
+				Username:           sc.GetUsername(),
+				Password:           sc.GetPassword(),
+				From:               sc.GetFrom(),
+				To:                 sc.GetTo(),
+				Cc:                 sc.GetCc(),
+			}
+		}
+		config.Notifiers = append(config.Notifiers, notifier)
+	}
+
+	return config, nil
+}
+
+// deployEvents 转换订阅的部署事件，不支持的事件返回错误
+func deployEvents(name string, values []string) ([]biz.DeployEvent, error) {
+	var events []biz.DeployEvent
+	for _, v := range values {
+		switch event := biz.DeployEvent(v); event {
+		case biz.EventStarted, biz.EventSucceeded, biz.EventFailed, biz.EventRolledBack:
+			events = append(events, event)
+		default:
+			return nil, fmt.Errorf("%s 包含不支持的部署事件: %s", name, v)
+		}
+	}
+	return events, nil
+}
+
+// messageFormat 转换通知消息格式，为空时为默认的纯文本
+func messageFormat(name, value string) (biz.MessageFormat, error) {
+	switch format := biz.MessageFormat(value); format {
+	case "", biz.MessageText, biz.MessageRich:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%s 不支持的消息格式: %s", name, value)
+	}
+}
+
+// stringList 转换字符串列表，数字等标量按文本处理
+func stringList(list *structpb.ListValue) []string {
+	var result []string
+	for _, v := range list.GetValues() {
+		switch kind := v.GetKind().(type) {
+		case *structpb.Value_StringValue:
+			result = append(result, kind.StringValue)
+		case *structpb.Value_NumberValue:
+			result = append(result, strconv.FormatFloat(kind.NumberValue, 'f', -1, 64))
+		case *structpb.Value_BoolValue:
+			result = append(result, strconv.FormatBool(kind.BoolValue))
+		}
+	}
+	return result
+}
+
+// resources 转换容器资源配置
+func resources(r *conf.Resources) *biz.Resources {
+	if r == nil {
+		return nil
+	}
+	return &biz.Resources{
+		CPURequest:    r.GetCpuRequest(),
+		MemoryRequest: r.GetMemoryRequest(),
+		CPULimit:      r.GetCpuLimit(),
+		MemoryLimit:   r.GetMemoryLimit(),
+	}
+}
+
+// ports 转换端口配置
+func ports(ps []*conf.Port) []*biz.Port {
+	var result []*biz.Port
+	for _, p := range ps {
+		result = append(result, &biz.Port{
+			Name:       p.GetName(),
+			Port:       p.GetPort(),
+			TargetPort: p.GetTargetPort(),
+			Protocol:   p.GetProtocol(),
+		})
+	}
+	return result
+}
+
+// envVars 转换环境变量配置
+func envVars(es []*conf.EnvVar) []*biz.EnvVar {
+	var result []*biz.EnvVar
+	for _, e := range es {
+		result = append(result, &biz.EnvVar{Name: e.GetName(), Value: e.GetValue()})
+	}
+	return result
+}
+
+// containers 转换附加容器配置
+func containers(list []*conf.Container) []*biz.Container {
+	var result []*biz.Container
+	for _, c := range list {
+		result = append(result, &biz.Container{
+			Name:         c.GetName(),
+			Image:        c.GetImage(),
+			Command:      c.GetCommand(),
+			Args:         c.GetArgs(),
+			EnvVars:      envVars(c.GetEnvVars()),
+			Ports:        ports(c.GetPorts()),
+			Resources:    resources(c.GetResources()),
+			VolumeMounts: volumeMounts(c.GetVolumeMounts()),
+		})
+	}
+	return result
+}
+
+// volumeMounts 转换卷挂载配置
+func volumeMounts(list []*conf.VolumeMount) []*biz.VolumeMount {
+	var result []*biz.VolumeMount
+	for _, m := range list {
+		result = append(result, &biz.VolumeMount{
+			Name:      m.GetName(),
+			MountPath: m.GetMountPath(),
+			SubPath:   m.GetSubPath(),
+			ReadOnly:  m.GetReadOnly(),
+		})
+	}
+	return result
+}
@@ -0,0 +1,813 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+const baseConfig = `deploy:
+  project_name: "demo"
+  namespace: "default"
+  version: "v1.0.0"
+  docker:
+    registry: "docker.io"
+    image_name: "demo:latest"
+  k8s:
+    namespace: "demo-dev"
+    deployment_name: "demo"
+    replicas: 1
+    ports:
+      - name: "http"
+        port: 80
+        target_port: 8080
+    env_vars:
+      - name: "LOG_LEVEL"
+        value: "debug"
+`
+
+const prodOverlay = `deploy:
+  docker:
+    image_name: "demo:v1.0.0"
+  k8s:
+    namespace: "demo-prod"
+    replicas: 3
+    env_vars:
+      - name: "LOG_LEVEL"
+        value: "info"
+`
+
+func writeConfigs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+// loadTestConfig 加载单个配置文件
+func loadTestConfig(t *testing.T, content string) *biz.DeployConfig {
+	t.Helper()
+	config, _, err := loadDeployConfig(writeConfigs(t, map[string]string{"config.yaml": content}), "dev")
+	if err != nil {
+		t.Fatalf("loadDeployConfig() error = %v", err)
+	}
+	return config
+}
+
+// TestLoadDeployConfigOverlay 环境覆盖配置覆盖同名字段、整体替换列表，未覆盖的字段保留基础配置
+func TestLoadDeployConfigOverlay(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": baseConfig, "config.prod.yaml": prodOverlay})
+	t.Setenv("DRONE_BRANCH", "main")
+	t.Setenv("DRONE_COMMIT_SHA", "4be0c7d")
+
+	config, files, err := loadDeployConfig(path, "prod")
+	if err != nil {
+		t.Fatalf("loadDeployConfig() error = %v", err)
+	}
+	if len(files) != 2 || files[1] != overlayPath(path, "prod") {
+		t.Errorf("已加载配置 = %v", files)
+	}
+	if config.Env != "prod" || config.Branch != "main" || config.Commit != "4be0c7d" {
+		t.Errorf("env = %s, branch = %s, commit = %s", config.Env, config.Branch, config.Commit)
+	}
+	if config.Docker.ImageName != "demo:v1.0.0" || config.Docker.Registry != "docker.io" {
+		t.Errorf("docker = %+v", config.Docker)
+	}
+	k8s := config.K8s
+	if k8s.Namespace != "demo-prod" || k8s.Replicas != 3 || k8s.DeploymentName != "demo" {
+		t.Errorf("k8s namespace = %s, replicas = %d, deployment = %s", k8s.Namespace, k8s.Replicas, k8s.DeploymentName)
+	}
+	if len(k8s.Ports) != 1 || k8s.Ports[0].TargetPort != 8080 {
+		t.Errorf("ports = %v", k8s.Ports)
+	}
+	if len(k8s.EnvVars) != 1 || k8s.EnvVars[0].Value != "info" {
+		t.Errorf("env_vars = %v", k8s.EnvVars)
+	}
+}
+
+// TestLoadDeployConfigWithoutOverlay 没有环境覆盖配置时只加载基础配置
+func TestLoadDeployConfigWithoutOverlay(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": baseConfig})
+
+	config, files, err := loadDeployConfig(path, "staging")
+	if err != nil {
+		t.Fatalf("loadDeployConfig() error = %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("已加载配置 = %v", files)
+	}
+	if config.Env != "staging" || config.K8s.Namespace != "demo-dev" || config.K8s.Replicas != 1 {
+		t.Errorf("env = %s, namespace = %s, replicas = %d", config.Env, config.K8s.Namespace, config.K8s.Replicas)
+	}
+}
+
+// TestLoadDeployConfigEnv 未指定 -env 时使用配置中的 env 选择覆盖配置
+func TestLoadDeployConfigEnv(t *testing.T) {
+	path := writeConfigs(t, map[string]string{
+		"config.yaml":      baseConfig + "  env: \"prod\"\n",
+		"config.prod.yaml": prodOverlay,
+	})
+
+	config, files, err := loadDeployConfig(path, "")
+	if err != nil {
+		t.Fatalf("loadDeployConfig() error = %v", err)
+	}
+	if config.Env != "prod" || len(files) != 2 || config.K8s.Replicas != 3 {
+		t.Errorf("env = %s, files = %v, replicas = %d", config.Env, files, config.K8s.Replicas)
+	}
+
+	if config, _, _ = loadDeployConfig(writeConfigs(t, map[string]string{"config.yaml": baseConfig}), ""); config.Env != "dev" {
+		t.Errorf("未配置 env 时 env = %s, want dev", config.Env)
+	}
+}
+
+// TestLoadDeployConfigUnknownKey 未知的配置项导致加载失败，而不是被忽略
+func TestLoadDeployConfigUnknownKey(t *testing.T) {
+	path := writeConfigs(t, map[string]string{
+		"config.yaml":      baseConfig,
+		"config.prod.yaml": "deploy:\n  k8s:\n    replica: 3\n",
+	})
+
+	_, _, err := loadDeployConfig(path, "prod")
+	if err == nil || !strings.Contains(err.Error(), "replica") {
+		t.Fatalf("loadDeployConfig() error = %v, want 未知字段 replica", err)
+	}
+}
+
+func TestLoadDeployConfigAutoscaling(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    autoscaling:
+      min_replicas: 2
+      max_replicas: 10
+      target_cpu_utilization_percentage: 70
+    disruption:
+      min_available: 1
+`)
+
+	want := &biz.Autoscaling{MinReplicas: 2, MaxReplicas: 10, TargetCPUUtilizationPercentage: 70}
+	if got := config.K8s.Autoscaling; got == nil || *got != *want {
+		t.Errorf("autoscaling = %+v, want %+v", got, want)
+	}
+	if got := config.K8s.Disruption; got == nil || got.MinAvailable != "1" || got.MaxUnavailable != "" {
+		t.Errorf("disruption = %+v", got)
+	}
+}
+
+func TestLoadDeployConfigScheduling(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    strategy:
+      max_surge: 1
+      max_unavailable: "25%"
+    node_selector:
+      disktype: "ssd"
+    tolerations:
+      - key: "dedicated"
+        operator: "Equal"
+        value: "app"
+        effect: "NoExecute"
+        toleration_seconds: 60
+    affinity:
+      required_node_labels:
+        zone: ["a", "b"]
+      pod_anti_affinity: "preferred"
+    topology_spread_constraints:
+      - topology_key: "topology.kubernetes.io/zone"
+        max_skew: 1
+        when_unsatisfiable: "ScheduleAnyway"
+    service_account_name: "demo"
+    image_pull_secrets: ["regcred"]
+    security_context:
+      run_as_non_root: false
+      run_as_user: 1000
+      read_only_root_filesystem: true
+      drop_capabilities: ["NET_RAW"]
+      seccomp_profile: "Unconfined"
+`)
+
+	k8s := config.K8s
+	if k8s.Strategy == nil || k8s.Strategy.MaxSurge != "1" || k8s.Strategy.MaxUnavailable != "25%" {
+		t.Errorf("strategy = %+v", k8s.Strategy)
+	}
+	if k8s.NodeSelector["disktype"] != "ssd" {
+		t.Errorf("node_selector = %v", k8s.NodeSelector)
+	}
+	if len(k8s.Tolerations) != 1 || k8s.Tolerations[0].Effect != "NoExecute" || *k8s.Tolerations[0].TolerationSeconds != 60 {
+		t.Errorf("tolerations = %+v", k8s.Tolerations)
+	}
+	if a := k8s.Affinity; a == nil || strings.Join(a.RequiredNodeLabels["zone"], ",") != "a,b" || a.PodAntiAffinity != "preferred" {
+		t.Errorf("affinity = %+v", a)
+	}
+	if len(k8s.TopologySpreadConstraints) != 1 || k8s.TopologySpreadConstraints[0].MaxSkew != 1 {
+		t.Errorf("topology_spread_constraints = %+v", k8s.TopologySpreadConstraints)
+	}
+	if k8s.ServiceAccountName != "demo" || strings.Join(k8s.ImagePullSecrets, ",") != "regcred" {
+		t.Errorf("service_account_name = %s, image_pull_secrets = %v", k8s.ServiceAccountName, k8s.ImagePullSecrets)
+	}
+
+	sc := k8s.SecurityContext
+	if sc == nil || sc.RunAsNonRoot == nil || *sc.RunAsNonRoot || *sc.RunAsUser != 1000 || !*sc.ReadOnlyRootFilesystem {
+		t.Fatalf("security_context = %+v", sc)
+	}
+	if sc.AllowPrivilegeEscalation != nil || sc.SeccompProfile != "Unconfined" || strings.Join(sc.DropCapabilities, ",") != "NET_RAW" {
+		t.Errorf("security_context = %+v", sc)
+	}
+}
+
+func TestLoadDeployConfigManifests(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    manifests:
+      paths: ["./deploy/extra"]
+      kustomization: "./deploy/overlays/prod"
+      template: true
+      vars:
+        Replicas: 3
+`)
+
+	m := config.K8s.Manifests
+	if m == nil || strings.Join(m.Paths, ",") != "./deploy/extra" || m.Kustomization != "./deploy/overlays/prod" || !m.Template {
+		t.Fatalf("manifests = %+v", m)
+	}
+	if m.Vars["Replicas"] != "3" {
+		t.Errorf("manifests.vars = %v", m.Vars)
+	}
+}
+
+func TestLoadDeployConfigPrune(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    release: "demo-prod"
+    prune: true
+`)
+
+	if config.K8s.Release != "demo-prod" || !config.K8s.Prune {
+		t.Errorf("release = %s, prune = %v", config.K8s.Release, config.K8s.Prune)
+	}
+}
+
+func TestLoadDeployConfigHooks(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    hooks:
+      - name: "migrate"
+        phase: "pre-deploy"
+        command: ["./app", "migrate"]
+        args: ["--lock"]
+        timeout: 5m
+        backoff_limit: 2
+        env_vars:
+          - name: "MIGRATE_LOCK"
+            value: "true"
+`)
+
+	if len(config.K8s.Hooks) != 1 {
+		t.Fatalf("hooks = %v", config.K8s.Hooks)
+	}
+	hook := config.K8s.Hooks[0]
+	if hook.Name != "migrate" || hook.Phase != biz.HookPreDeploy || strings.Join(hook.Command, " ") != "./app migrate" || hook.Args[0] != "--lock" {
+		t.Errorf("hook = %+v", hook)
+	}
+	if hook.Timeout != 5*time.Minute || hook.BackoffLimit != 2 || hook.EnvVars[0].Value != "true" {
+		t.Errorf("hook timeout = %s, backoff_limit = %d, env_vars = %v", hook.Timeout, hook.BackoffLimit, hook.EnvVars)
+	}
+}
+
+// TestLoadDeployConfigBootstrap 命名空间初始化配置，未加引号的数量按字符串处理
+func TestLoadDeployConfigBootstrap(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    namespace: "team-a"
+    bootstrap:
+      labels:
+        team: "a"
+      annotations:
+        owner: "platform"
+      pod_security: "restricted"
+      resource_quota:
+        requests.cpu: 4
+        pods: 20
+      limit_range:
+        default:
+          cpu: "500m"
+        default_request:
+          memory: "128Mi"
+        max:
+          cpu: 2
+        min:
+          cpu: "50m"
+      image_pull_secret: "regcred"
+      manage: true
+`)
+
+	b := config.K8s.Bootstrap
+	if b == nil {
+		t.Fatal("bootstrap 未加载")
+	}
+	if b.Labels["team"] != "a" || b.Annotations["owner"] != "platform" || b.PodSecurity != "restricted" {
+		t.Errorf("bootstrap = %+v", b)
+	}
+	if b.ResourceQuota["requests.cpu"] != "4" || b.ResourceQuota["pods"] != "20" {
+		t.Errorf("resource_quota = %v", b.ResourceQuota)
+	}
+	lr := b.LimitRange
+	if lr == nil || lr.Default["cpu"] != "500m" || lr.DefaultRequest["memory"] != "128Mi" || lr.Max["cpu"] != "2" || lr.Min["cpu"] != "50m" {
+		t.Errorf("limit_range = %+v", lr)
+	}
+	if b.ImagePullSecret != "regcred" || !b.Manage {
+		t.Errorf("image_pull_secret = %s, manage = %v", b.ImagePullSecret, b.Manage)
+	}
+}
+
+// TestLoadDeployConfigTargets 多集群部署目标及失败处理策略
+func TestLoadDeployConfigTargets(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    parallel: true
+    failure_policy: "rollback"
+    targets:
+      - name: "east"
+        kubeconfig_path: "/etc/kube/east"
+        context: "east-admin"
+        namespace: "demo-east"
+      - name: "west"
+        kubeconfig: "apiVersion: v1"
+        server: "https://west.example.com"
+        token: "t0ken"
+        token_file: "/var/run/token"
+        ca_file: "/etc/ca.pem"
+        ca_data: "LS0t"
+        insecure_skip_tls_verify: true
+`)
+
+	k := config.K8s
+	if !k.Parallel || k.FailurePolicy != biz.FailureRollback || len(k.Targets) != 2 {
+		t.Fatalf("parallel = %v, failure_policy = %s, targets = %v", k.Parallel, k.FailurePolicy, k.Targets)
+	}
+	east, west := k.Targets[0], k.Targets[1]
+	if east.Name != "east" || east.KubeconfigPath != "/etc/kube/east" || east.Context != "east-admin" || east.Namespace != "demo-east" {
+		t.Errorf("targets[0] = %+v", east)
+	}
+	if west.Kubeconfig != "apiVersion: v1" || west.Server != "https://west.example.com" || west.Token != "t0ken" || west.TokenFile != "/var/run/token" ||
+		west.CAFile != "/etc/ca.pem" || west.CAData != "LS0t" || !west.InsecureSkipTLSVerify {
+		t.Errorf("targets[1] = %+v", west)
+	}
+
+	path := writeConfigs(t, map[string]string{"config.yaml": "deploy:\n  k8s:\n    failure_policy: retry\n"})
+	if _, _, err := loadDeployConfig(path, "dev"); err == nil || !strings.Contains(err.Error(), "retry") {
+		t.Fatalf("loadDeployConfig() error = %v, want 不支持的失败处理策略", err)
+	}
+}
+
+// TestLoadDeployConfigConnection 集群连接、认证与客户端限流配置
+func TestLoadDeployConfigConnection(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    kubeconfig: "apiVersion: v1"
+    context: "prod-cn"
+    in_cluster: true
+    server: "https://10.0.0.1:6443"
+    token: "t0ken"
+    token_file: "/var/run/token"
+    ca_file: "/etc/ca.pem"
+    ca_data: "LS0t"
+    insecure_skip_tls_verify: true
+    qps: 100
+    burst: 200
+    request_timeout: 1m
+`)
+
+	k := config.K8s
+	if k.Kubeconfig != "apiVersion: v1" || k.Context != "prod-cn" || !k.InCluster || k.Server != "https://10.0.0.1:6443" {
+		t.Errorf("k8s = %+v", k)
+	}
+	if k.Token != "t0ken" || k.TokenFile != "/var/run/token" || k.CAFile != "/etc/ca.pem" || k.CAData != "LS0t" || !k.InsecureSkipTLSVerify {
+		t.Errorf("认证配置 = %+v", k)
+	}
+	if k.QPS != 100 || k.Burst != 200 || k.RequestTimeout != time.Minute {
+		t.Errorf("qps = %v, burst = %d, request_timeout = %s", k.QPS, k.Burst, k.RequestTimeout)
+	}
+}
+
+// TestLoadDeployConfigImage 主容器镜像与应用版本，未加引号的版本号按字符串处理
+func TestLoadDeployConfigImage(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    image: "registry/app:v1"
+    version: 1.2
+`)
+
+	if config.K8s.Image != "registry/app:v1" || config.K8s.Version != "1.2" {
+		t.Errorf("image = %s, version = %s", config.K8s.Image, config.K8s.Version)
+	}
+}
+
+// TestLoadDeployConfigLabels 推荐标签、附加标签与注解
+func TestLoadDeployConfigLabels(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    app_name: "order"
+    component: "api"
+    part_of: "shop"
+    labels:
+      team: "a"
+    annotations:
+      owner: "platform"
+    pod_annotations:
+      prometheus.io/scrape: true
+`)
+
+	k := config.K8s
+	if k.AppName != "order" || k.Component != "api" || k.PartOf != "shop" {
+		t.Errorf("app_name = %s, component = %s, part_of = %s", k.AppName, k.Component, k.PartOf)
+	}
+	if k.Labels["team"] != "a" || k.Annotations["owner"] != "platform" || k.PodAnnotations["prometheus.io/scrape"] != "true" {
+		t.Errorf("labels = %v, annotations = %v, pod_annotations = %v", k.Labels, k.Annotations, k.PodAnnotations)
+	}
+}
+
+// TestLoadDeployConfigContainers 附加容器、共享卷与主容器挂载
+func TestLoadDeployConfigContainers(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    init_containers:
+      - name: "wait-for-db"
+        image: "busybox:1.36"
+        command: ["sh", "-c"]
+        args: ["until nc -z db 5432; do sleep 2; done"]
+    sidecars:
+      - name: "log-shipper"
+        env_vars:
+          - name: "LEVEL"
+            value: "info"
+        ports:
+          - name: "metrics"
+            port: 2020
+        resources:
+          cpu_request: "50m"
+        volume_mounts:
+          - name: "logs"
+            mount_path: "/var/log/app"
+            read_only: true
+    volumes:
+      - name: "logs"
+        empty_dir: {}
+      - name: "cache"
+        empty_dir:
+          medium: "Memory"
+          size_limit: "64Mi"
+      - name: "config"
+        config_map: "app-config"
+      - name: "tls"
+        secret: "app-tls"
+      - name: "data"
+        persistent_volume_claim: "app-data"
+        read_only: true
+    volume_mounts:
+      - name: "config"
+        mount_path: "/etc/app"
+        sub_path: "app.yaml"
+`)
+
+	k := config.K8s
+	if len(k.InitContainers) != 1 || len(k.Sidecars) != 1 {
+		t.Fatalf("init_containers = %v, sidecars = %v", k.InitContainers, k.Sidecars)
+	}
+	init := k.InitContainers[0]
+	if init.Name != "wait-for-db" || init.Image != "busybox:1.36" || len(init.Command) != 2 || len(init.Args) != 1 {
+		t.Errorf("init_containers[0] = %+v", init)
+	}
+	sidecar := k.Sidecars[0]
+	if sidecar.EnvVars[0].Value != "info" || sidecar.Ports[0].Port != 2020 || sidecar.Resources.CPURequest != "50m" ||
+		sidecar.VolumeMounts[0].MountPath != "/var/log/app" || !sidecar.VolumeMounts[0].ReadOnly {
+		t.Errorf("sidecars[0] = %+v", sidecar)
+	}
+
+	if len(k.Volumes) != 5 {
+		t.Fatalf("volumes = %v", k.Volumes)
+	}
+	if k.Volumes[0].EmptyDir == nil {
+		t.Error("empty_dir: {} 未加载")
+	}
+	if e := k.Volumes[1].EmptyDir; e == nil || e.Medium != "Memory" || e.SizeLimit != "64Mi" {
+		t.Errorf("volumes[1].empty_dir = %+v", e)
+	}
+	if k.Volumes[2].ConfigMap != "app-config" || k.Volumes[3].Secret != "app-tls" ||
+		k.Volumes[4].PersistentVolumeClaim != "app-data" || !k.Volumes[4].ReadOnly {
+		t.Errorf("volumes = %+v %+v %+v", k.Volumes[2], k.Volumes[3], k.Volumes[4])
+	}
+	if len(k.VolumeMounts) != 1 || k.VolumeMounts[0].SubPath != "app.yaml" {
+		t.Errorf("volume_mounts = %v", k.VolumeMounts)
+	}
+}
+
+// TestLoadDeployConfigNotifiers 单渠道配置与更多通知渠道
+func TestLoadDeployConfigNotifiers(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  notify:
+    enabled: true
+    type: "dingtalk"
+    webhook_url: "https://oapi.dingtalk.com/robot/send?access_token=x"
+    secret: "SEC1"
+    notifiers:
+      - name: "ops"
+        type: "slack"
+        webhook_url: "https://hooks.slack.com/services/x"
+        channel: "#deployment"
+      - type: "feishu"
+        webhook_url: "https://open.feishu.cn/open-apis/bot/v2/hook/x"
+        secret: "SEC2"
+`)
+
+	n := config.Notify
+	if !n.Enabled || n.Type != biz.NotifierDingTalk || n.Secret != "SEC1" || n.WebhookURL == "" {
+		t.Errorf("notify = %+v", n)
+	}
+	if len(n.Notifiers) != 2 {
+		t.Fatalf("notifiers = %v", n.Notifiers)
+	}
+	if ops := n.Notifiers[0]; ops.Name != "ops" || ops.Type != biz.NotifierSlack || ops.Channel != "#deployment" {
+		t.Errorf("notifiers[0] = %+v", ops)
+	}
+	if feishu := n.Notifiers[1]; feishu.Type != biz.NotifierFeishu || feishu.Secret != "SEC2" {
+		t.Errorf("notifiers[1] = %+v", feishu)
+	}
+}
+
+// TestLoadDeployConfigEvents 通知订阅的部署事件，渠道上的 events 单独加载
+func TestLoadDeployConfigEvents(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  notify:
+    enabled: true
+    events: ["failed", "rolled_back"]
+    notifiers:
+      - type: "wecom"
+        webhook_url: "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=x"
+        events: ["succeeded"]
+`)
+
+	n := config.Notify
+	if len(n.Events) != 2 || n.Events[0] != biz.EventFailed || n.Events[1] != biz.EventRolledBack {
+		t.Errorf("events = %v", n.Events)
+	}
+	if events := n.Notifiers[0].Events; len(events) != 1 || events[0] != biz.EventSucceeded {
+		t.Errorf("notifiers[0].events = %v", events)
+	}
+
+	path := writeConfigs(t, map[string]string{"config.yaml": "deploy:\n  notify:\n    events: [success]\n"})
+	if _, _, err := loadDeployConfig(path, "dev"); err == nil || !strings.Contains(err.Error(), "success") {
+		t.Fatalf("loadDeployConfig() error = %v, want 不支持的部署事件", err)
+	}
+}
+
+// TestLoadDeployConfigTemplates 消息格式、模板语言与自定义模板
+func TestLoadDeployConfigTemplates(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  notify:
+    enabled: true
+    format: "rich"
+    language: "en"
+    templates:
+      succeeded:
+        title: "{{.Project}} deployed to {{.Env}}"
+        text: "version {{.Version}}"
+    notifiers:
+      - type: "wecom"
+        webhook_url: "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=x"
+        format: "text"
+`)
+
+	n := config.Notify
+	if n.Format != biz.MessageRich || n.Language != "en" || n.Notifiers[0].Format != biz.MessageText {
+		t.Errorf("format = %s, language = %s, notifiers[0].format = %s", n.Format, n.Language, n.Notifiers[0].Format)
+	}
+	tmpl := n.Templates[biz.EventSucceeded]
+	if len(n.Templates) != 1 || tmpl == nil || tmpl.Title != "{{.Project}} deployed to {{.Env}}" || tmpl.Text != "version {{.Version}}" {
+		t.Errorf("templates = %v", n.Templates)
+	}
+
+	path := writeConfigs(t, map[string]string{"config.yaml": "deploy:\n  notify:\n    templates:\n      done:\n        title: x\n"})
+	if _, _, err := loadDeployConfig(path, "dev"); err == nil || !strings.Contains(err.Error(), "done") {
+		t.Fatalf("loadDeployConfig() error = %v, want 不支持的部署事件", err)
+	}
+}
+
+// TestLoadDeployConfigSMTP 邮件渠道配置
+func TestLoadDeployConfigSMTP(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  notify:
+    enabled: true
+    notifiers:
+      - name: "release-managers"
+        type: "email"
+        smtp:
+          host: "smtp.example.com"
+          port: 465
+          security: "tls"
+          insecure_skip_verify: true
+          username: "deploy@example.com"
+          password: "secret"
+          from: "Deploy Bot <deploy@example.com>"
+          to: ["release@example.com"]
+          cc: ["ops@example.com"]
+`)
+
+	notifier := config.Notify.Notifiers[0]
+	if notifier.Type != biz.NotifierEmail || notifier.SMTP == nil {
+		t.Fatalf("notifiers[0] = %+v", notifier)
+	}
+	smtp := notifier.SMTP
+	if smtp.Host != "smtp.example.com" || smtp.Port != 465 || smtp.Security != biz.SMTPTLS || !smtp.InsecureSkipVerify {
+		t.Errorf("smtp = %+v", smtp)
+	}
+	if smtp.Username != "deploy@example.com" || smtp.Password != "secret" || smtp.From != "Deploy Bot <deploy@example.com>" ||
+		len(smtp.To) != 1 || smtp.To[0] != "release@example.com" || len(smtp.Cc) != 1 || smtp.Cc[0] != "ops@example.com" {
+		t.Errorf("smtp = %+v", smtp)
+	}
+}
+
+// TestLoadDeployConfigNotifyRetry 通知超时、重试策略与发件箱
+func TestLoadDeployConfigNotifyRetry(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  notify:
+    enabled: true
+    timeout: 5s
+    retry:
+      max_attempts: 5
+      initial_backoff: 2s
+      max_backoff: 1m
+    outbox_dir: "/var/lib/go-drone-deploy/outbox"
+    outbox_max_age: 48h
+    outbox_flush_timeout: 30s
+`)
+
+	n := config.Notify
+	if n.Timeout != 5*time.Second || n.Retry == nil {
+		t.Fatalf("timeout = %s, retry = %v", n.Timeout, n.Retry)
+	}
+	if n.Retry.MaxAttempts != 5 || n.Retry.InitialBackoff != 2*time.Second || n.Retry.MaxBackoff != time.Minute {
+		t.Errorf("retry = %+v", n.Retry)
+	}
+	if n.OutboxDir != "/var/lib/go-drone-deploy/outbox" || n.OutboxMaxAge != 48*time.Hour || n.OutboxFlushTimeout != 30*time.Second {
+		t.Errorf("outbox_dir = %s, outbox_max_age = %s, outbox_flush_timeout = %s", n.OutboxDir, n.OutboxMaxAge, n.OutboxFlushTimeout)
+	}
+}
+
+// TestLoadDeployConfigWebhooks 部署事件 Webhook 与事件目录
+func TestLoadDeployConfigWebhooks(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  notify:
+    enabled: true
+    event_dir: "/var/lib/go-drone-deploy/events"
+    webhooks:
+      - name: "release-dashboard"
+        url: "https://release.example.com/api/deploy-events"
+        secret: "s3cret"
+        events: ["succeeded", "failed"]
+        format: "cloudevents"
+        headers:
+          X-Team: "platform"
+`)
+
+	n := config.Notify
+	if n.EventDir != "/var/lib/go-drone-deploy/events" || len(n.Webhooks) != 1 {
+		t.Fatalf("event_dir = %s, webhooks = %v", n.EventDir, n.Webhooks)
+	}
+	w := n.Webhooks[0]
+	if w.Name != "release-dashboard" || w.URL != "https://release.example.com/api/deploy-events" || w.Secret != "s3cret" {
+		t.Errorf("webhooks[0] = %+v", w)
+	}
+	if len(w.Events) != 2 || w.Events[1] != biz.EventFailed || w.Format != biz.WebhookCloudEvents || w.Headers["X-Team"] != "platform" {
+		t.Errorf("webhooks[0] = %+v", w)
+	}
+
+	path := writeConfigs(t, map[string]string{"config.yaml": "deploy:\n  notify:\n    webhooks:\n      - url: http://x\n        format: xml\n"})
+	if _, _, err := loadDeployConfig(path, "dev"); err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Fatalf("loadDeployConfig() error = %v, want 不支持的 Webhook 格式", err)
+	}
+}
+
+// TestLoadDeployConfigPipelines 自定义流水线及镜像扫描、签名配置
+func TestLoadDeployConfigPipelines(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  docker:
+    image_name: "demo:v1"
+    scan:
+      severity: "CRITICAL"
+      ignore_unfixed: true
+    sign:
+      key: "cosign.key"
+  pipelines:
+    release:
+      quiet: true
+      steps:
+        - name: build
+        - name: scan
+          needs: [build]
+          continue_on_error: true
+          when:
+            env: [dev, staging]
+            branch: ["release/*"]
+        - name: migrate
+          uses: hooks
+          needs: [build]
+          with:
+            phase: pre-deploy
+`)
+
+	docker := config.Docker
+	if docker.Scan == nil || docker.Scan.Severity != "CRITICAL" || !docker.Scan.IgnoreUnfixed || docker.Sign == nil || docker.Sign.Key != "cosign.key" {
+		t.Errorf("scan = %+v, sign = %+v", docker.Scan, docker.Sign)
+	}
+
+	pipeline := config.Pipelines["release"]
+	if pipeline == nil || !pipeline.Quiet || len(pipeline.Steps) != 3 {
+		t.Fatalf("pipelines = %v", config.Pipelines)
+	}
+	scan := pipeline.Steps[1]
+	if scan.Name != "scan" || scan.Needs[0] != "build" || !scan.ContinueOnError || scan.When == nil ||
+		len(scan.When.Env) != 2 || scan.When.Branch[0] != "release/*" {
+		t.Errorf("steps[1] = %+v", scan)
+	}
+	if migrate := pipeline.Steps[2]; migrate.Uses != "hooks" || migrate.With["phase"] != "pre-deploy" {
+		t.Errorf("steps[2] = %+v", migrate)
+	}
+}
+
+// TestLoadDeployConfigStepPolicies 按步骤设置的超时与重试策略，步骤上的设置单独加载
+func TestLoadDeployConfigStepPolicies(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  step_policies:
+    push:
+      timeout: 10m
+      retry:
+        max_attempts: 3
+        initial_backoff: 10s
+        max_backoff: 2m
+        on: [network, server]
+  pipelines:
+    release:
+      steps:
+        - name: deploy
+          timeout: 15m
+          retry:
+            max_attempts: 2
+            on: [any]
+`)
+
+	push := config.StepPolicies["push"]
+	if push == nil || push.Timeout != 10*time.Minute || push.Retry == nil {
+		t.Fatalf("step_policies = %v", config.StepPolicies)
+	}
+	if r := push.Retry; r.MaxAttempts != 3 || r.InitialBackoff != 10*time.Second || r.MaxBackoff != 2*time.Minute ||
+		len(r.On) != 2 || r.On[0] != biz.ErrorNetwork || r.On[1] != biz.ErrorServer {
+		t.Errorf("step_policies.push.retry = %+v", r)
+	}
+	step := config.Pipelines["release"].Steps[0]
+	if step.Timeout != 15*time.Minute || step.Retry == nil || step.Retry.MaxAttempts != 2 || step.Retry.On[0] != biz.ErrorAny {
+		t.Errorf("steps[0] timeout = %s, retry = %+v", step.Timeout, step.Retry)
+	}
+
+	path := writeConfigs(t, map[string]string{"config.yaml": "deploy:\n  step_policies:\n    push:\n      retry:\n        on: [flaky]\n"})
+	if _, _, err := loadDeployConfig(path, "dev"); err == nil || !strings.Contains(err.Error(), "flaky") {
+		t.Fatalf("loadDeployConfig() error = %v, want 不支持的错误类别", err)
+	}
+}
+
+// TestLoadDeployConfigInvalidDuration 时间间隔格式错误时加载失败
+func TestLoadDeployConfigInvalidDuration(t *testing.T) {
+	path := writeConfigs(t, map[string]string{"config.yaml": "deploy:\n  k8s:\n    hooks:\n      - name: migrate\n        timeout: 5 分钟\n"})
+
+	_, _, err := loadDeployConfig(path, "dev")
+	if err == nil || !strings.Contains(err.Error(), "hooks.migrate.timeout") {
+		t.Fatalf("loadDeployConfig() error = %v", err)
+	}
+}
+
+func TestLoadDeployConfigRollout(t *testing.T) {
+	config := loadTestConfig(t, `deploy:
+  k8s:
+    deployment_name: "demo"
+    rollout_timeout: 10m
+    diagnostic_log_lines: 100
+`)
+
+	if config.K8s.RolloutTimeout != 10*time.Minute || config.K8s.DiagnosticLogLines != 100 {
+		t.Errorf("rollout_timeout = %s, diagnostic_log_lines = %d", config.K8s.RolloutTimeout, config.K8s.DiagnosticLogLines)
+	}
+}
@@ -2,17 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"go-drone-deploy/internal/biz"
 	"go-drone-deploy/internal/data"
 
-	"github.com/go-kratos/kratos/v2/config"
-	"github.com/go-kratos/kratos/v2/config/file"
 	"github.com/go-kratos/kratos/v2/log"
 
 	_ "go.uber.org/automaxprocs"
@@ -32,13 +32,25 @@ var (
 	flagenv string
 	// flagversion shows version info.
 	flagversion bool
+	// flagdryrun only diffs against the live cluster.
+	flagdryrun bool
+	// flagexitcode exits non-zero when the diff has changes.
+	flagexitcode bool
+	// flagoutdir is the kustomize base directory for the render command.
+	flagoutdir string
+	// flagoutput is the output format of plan and deploy results.
+	flagoutput string
 )
 
 func init() {
 	flag.StringVar(&flagconf, "conf", "./configs/config.yaml", "配置文件路径")
-	flag.StringVar(&flagflow, "flow", "all", "部署流程 (all/docker/k8s/notify/standard)")
-	flag.StringVar(&flagenv, "env", "dev", "部署环境 (dev/staging/prod)")
+	flag.StringVar(&flagflow, "flow", "all", "部署流程：内置流程 all/docker/k8s/notify/standard/set-image/restart，或配置中定义的流水线")
+	flag.StringVar(&flagenv, "env", "", "部署环境 (dev/staging/prod)，默认使用配置中的 env；存在时加载同目录下的 config.<env>.yaml 覆盖基础配置")
 	flag.BoolVar(&flagversion, "version", false, "显示版本信息")
+	flag.BoolVar(&flagdryrun, "dry-run", false, "仅以服务端 dry-run 对比集群差异，不做实际变更（等同于 diff 命令）；用于 prune 命令时仅列出将被清理的对象；用于 plan 命令时对比集群差异")
+	flag.BoolVar(&flagexitcode, "exit-code", false, "dry-run 存在差异时以非零状态码退出")
+	flag.StringVar(&flagoutdir, "out-dir", "", "render 命令输出 kustomize 基础目录，为空时输出多文档 YAML 到标准输出")
+	flag.StringVar(&flagoutput, "output", "text", "plan 命令和部署结果的输出格式 (text/json)")
 }
 
 func main() {
@@ -57,54 +69,24 @@ func main() {
 	// 处理信号
 	go handleSignals(cancel)
 
-	// 创建日志器
-	logger := log.With(log.NewStdLogger(os.Stdout),
+	// 创建日志器，对比差异、渲染清单、输出计划或 JSON 结果到标准输出时日志改写到标准错误，避免混入输出
+	logOutput := os.Stdout
+	if (flag.Arg(0) == "render" && flagoutdir == "") || flag.Arg(0) == "plan" || flag.Arg(0) == "diff" || flagdryrun || flagoutput == "json" {
+		logOutput = os.Stderr
+	}
+	logger := log.With(log.NewStdLogger(logOutput),
 		"ts", log.DefaultTimestamp,
 		"caller", log.DefaultCaller,
 		"service.name", Name,
 		"service.version", Version,
 	)
 
-	// 加载配置
-	c := config.New(
-		config.WithSource(
-			file.NewSource(flagconf),
-		),
-	)
-	defer c.Close()
-
-	if err := c.Load(); err != nil {
+	// 加载配置及环境覆盖配置
+	deployConfig, files, err := loadDeployConfig(flagconf, flagenv)
+	if err != nil {
 		log.NewHelper(logger).Fatalf("加载配置失败: %v", err)
 	}
-
-	// 创建简单的部署配置（示例）
-	deployConfig := &biz.DeployConfig{
-		ProjectName: "example-app",
-		Author:      "drone-deploy",
-		Namespace:   "default",
-		Version:     "latest",
-		Env:         flagenv,
-		Docker: &biz.DockerConfig{
-			Registry:       "docker.io",
-			ImageName:      "example-app:latest",
-			DockerfilePath: "./Dockerfile",
-			BuildContext:   ".",
-		},
-		K8s: &biz.K8sConfig{
-			Namespace:      "default",
-			DeploymentName: "example-app",
-			ServiceName:    "example-app-service",
-			Replicas:       1,
-			Ports: []*biz.Port{
-				{
-					Name:       "http",
-					Port:       80,
-					TargetPort: 8080,
-					Protocol:   "TCP",
-				},
-			},
-		},
-	}
+	log.NewHelper(logger).Infof("已加载配置: %s", strings.Join(files, ", "))
 
 	// 创建数据层和业务层
 	dataRepo, cleanup, err := data.NewData(nil, logger)
@@ -112,35 +94,149 @@ func main() {
 		log.NewHelper(logger).Fatalf("创建数据层失败: %v", err)
 	}
 	defer cleanup()
-	
+
 	deployRepo := data.NewDeployRepo(dataRepo, logger)
 	deployUC := biz.NewDeployUsecase(deployRepo, logger)
 
-	// 映射流程名称
-	var flow biz.DeployFlow
-	switch flagflow {
-	case "full":
+	// 清理已从配置中移除的资源
+	if flag.Arg(0) == "prune" {
+		pruned, err := deployUC.Prune(ctx, deployConfig, flagdryrun)
+		if err != nil {
+			log.NewHelper(logger).Fatalf("清理资源失败: %v", err)
+		}
+		for _, ref := range pruned {
+			if ref.Target != "" {
+				fmt.Printf("[%s] ", ref.Target)
+			}
+			fmt.Printf("%s %s/%s\n", ref.Kind, ref.Namespace, ref.Name)
+		}
+		return
+	}
+
+	// 流程名称，full 为 all 的旧名称
+	flow := biz.DeployFlow(flagflow)
+	if flow == "full" {
 		flow = biz.FlowAll
-	case "docker":
-		flow = biz.FlowDocker
-	case "k8s":
-		flow = biz.FlowK8s
-	case "notify":
-		flow = biz.FlowNotify
-	case "standard":
-		flow = biz.FlowStandard
-	default:
-		log.NewHelper(logger).Fatalf("不支持的部署流程: %s", flagflow)
+	}
+
+	// 输出部署计划，不做任何变更
+	if flag.Arg(0) == "plan" {
+		plan, err := deployUC.Plan(ctx, deployConfig, flow, flagdryrun)
+		if err != nil {
+			log.NewHelper(logger).Fatalf("生成部署计划失败: %v", err)
+		}
+		switch flagoutput {
+		case "json":
+			err = writePlanJSON(os.Stdout, plan)
+		case "text":
+			err = writePlan(os.Stdout, plan)
+		default:
+			log.NewHelper(logger).Fatalf("不支持的输出格式: %s", flagoutput)
+		}
+		if err != nil {
+			log.NewHelper(logger).Fatalf("输出部署计划失败: %v", err)
+		}
+		return
+	}
+
+	// 重放已记录的部署事件
+	if flag.Arg(0) == "replay" {
+		if flag.Arg(1) == "" {
+			log.NewHelper(logger).Fatal("用法: go-drone-deploy replay <部署 ID|事件 ID>")
+		}
+		sent, err := deployUC.Replay(ctx, deployConfig, flag.Arg(1))
+		if err != nil {
+			log.NewHelper(logger).Fatalf("重放部署事件失败（已发送 %d 个）: %v", sent, err)
+		}
+		log.NewHelper(logger).Infof("已重放 %d 个部署事件", sent)
+		return
+	}
+
+	// 对比集群差异
+	if flagdryrun || flag.Arg(0) == "diff" {
+		changed, err := runDiff(ctx, deployUC, deployConfig)
+		if err != nil {
+			log.NewHelper(logger).Fatalf("对比差异失败: %v", err)
+		}
+		if changed && flagexitcode {
+			cleanup()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 渲染资源清单
+	if flag.Arg(0) == "render" {
+		manifests, err := deployUC.Render(ctx, deployConfig)
+		if err != nil {
+			log.NewHelper(logger).Fatalf("渲染清单失败: %v", err)
+		}
+		if flagoutdir != "" {
+			err = writeKustomizeBase(flagoutdir, manifests)
+		} else {
+			err = writeManifests(os.Stdout, manifests)
+		}
+		if err != nil {
+			log.NewHelper(logger).Fatalf("输出清单失败: %v", err)
+		}
+		return
+	}
+
+	if flagoutput != "text" && flagoutput != "json" {
+		log.NewHelper(logger).Fatalf("不支持的输出格式: %s", flagoutput)
 	}
 
 	// 执行部署
-	if err := deployUC.Deploy(ctx, deployConfig, flow); err != nil {
+	result, err := deployUC.Deploy(ctx, deployConfig, flow)
+	// 输出部署结果，流水线未开始执行时没有结果
+	if result != nil {
+		var writeErr error
+		if flagoutput == "json" {
+			writeErr = writeResultJSON(os.Stdout, result)
+		} else {
+			writeErr = writeResult(os.Stdout, result)
+		}
+		if writeErr != nil {
+			log.NewHelper(logger).Errorf("输出部署结果失败: %v", writeErr)
+		}
+	}
+	if err != nil {
+		// 打印集群诊断摘要
+		var de *biz.DeployError
+		var te *biz.TargetsError
+		switch {
+		case errors.As(err, &te):
+			fmt.Fprintf(os.Stderr, "目标集群结果:\n%s\n", te.Summary())
+		case errors.As(err, &de) && de.Diagnostics != nil:
+			fmt.Fprintf(os.Stderr, "诊断信息:\n%s\n", de.Diagnostics.Summary())
+		}
 		log.NewHelper(logger).Fatalf("部署失败: %v", err)
 	}
 
 	log.NewHelper(logger).Info("部署完成")
 }
 
+// runDiff 打印集群差异，返回是否存在变更
+func runDiff(ctx context.Context, uc *biz.DeployUsecase, config *biz.DeployConfig) (bool, error) {
+	diffs, err := uc.Diff(ctx, config)
+	if err != nil {
+		return false, err
+	}
+
+	for _, d := range diffs {
+		if d.Target != "" {
+			fmt.Printf("==> [%s] %s %s/%s (%s)\n", d.Target, d.Kind, d.Namespace, d.Name, d.Action)
+		} else {
+			fmt.Printf("==> %s %s/%s (%s)\n", d.Kind, d.Namespace, d.Name, d.Action)
+		}
+		if d.Diff != "" {
+			fmt.Println(d.Diff)
+		}
+	}
+
+	return biz.HasChanges(diffs), nil
+}
+
 func handleSignals(cancel context.CancelFunc) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
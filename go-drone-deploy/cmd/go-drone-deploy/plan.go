@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// planJSON 部署计划的 JSON 输出
+type planJSON struct {
+	Flow          biz.DeployFlow   `json:"flow"`
+	Project       string           `json:"project"`
+	Env           string           `json:"env"`
+	Version       string           `json:"version"`
+	Author        string           `json:"author,omitempty"`
+	Branch        string           `json:"branch,omitempty"`
+	Commit        string           `json:"commit,omitempty"`
+	BuildLink     string           `json:"buildLink,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	Steps         []planStepJSON   `json:"steps"`
+	Targets       []planTargetJSON `json:"targets"`
+	Objects       []planObjectJSON `json:"objects"`
+	Live          bool             `json:"live"`
+	Prune         bool             `json:"prune"`
+	Notifications []planNotifyJSON `json:"notifications"`
+}
+
+type planStepJSON struct {
+	Name            string   `json:"name"`
+	Uses            string   `json:"uses"`
+	Needs           []string `json:"needs,omitempty"`
+	Skip            bool     `json:"skip"`
+	ContinueOnError bool     `json:"continueOnError,omitempty"`
+	Timeout         string   `json:"timeout,omitempty"`
+	MaxAttempts     int      `json:"maxAttempts"`
+}
+
+type planTargetJSON struct {
+	Name      string `json:"name,omitempty"`
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+}
+
+type planObjectJSON struct {
+	Target    string         `json:"target,omitempty"`
+	Kind      string         `json:"kind"`
+	Namespace string         `json:"namespace,omitempty"`
+	Name      string         `json:"name"`
+	Action    biz.DiffAction `json:"action"`
+}
+
+type planNotifyJSON struct {
+	Event   biz.DeployEvent `json:"event"`
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+}
+
+// writePlanJSON 以 JSON 输出部署计划
+func writePlanJSON(w io.Writer, plan *biz.Plan) error {
+	out := planJSON{
+		Flow:          plan.Flow,
+		Project:       plan.Project,
+		Env:           plan.Env,
+		Version:       plan.Version,
+		Author:        plan.Author,
+		Branch:        plan.Branch,
+		Commit:        plan.Commit,
+		BuildLink:     plan.BuildLink,
+		Image:         plan.Image,
+		Steps:         []planStepJSON{},
+		Targets:       []planTargetJSON{},
+		Objects:       []planObjectJSON{},
+		Live:          plan.Live,
+		Prune:         plan.Prune,
+		Notifications: []planNotifyJSON{},
+	}
+	for _, s := range plan.Steps {
+		step := planStepJSON{Name: s.Name, Uses: s.Uses, Needs: s.Needs, Skip: s.Skip, ContinueOnError: s.ContinueOnError, MaxAttempts: s.MaxAttempts}
+		if s.Timeout > 0 {
+			step.Timeout = s.Timeout.String()
+		}
+		out.Steps = append(out.Steps, step)
+	}
+	for _, t := range plan.Targets {
+		out.Targets = append(out.Targets, planTargetJSON{Name: t.Name, Cluster: t.Cluster, Namespace: t.Namespace})
+	}
+	for _, o := range plan.Objects {
+		out.Objects = append(out.Objects, planObjectJSON{Target: o.Target, Kind: o.Kind, Namespace: o.Namespace, Name: o.Name, Action: o.Action})
+	}
+	for _, n := range plan.Notifications {
+		out.Notifications = append(out.Notifications, planNotifyJSON{Event: n.Event, Channel: n.Channel, Type: n.Type})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// writePlan 以可读格式输出部署计划
+func writePlan(w io.Writer, plan *biz.Plan) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "流程:\t%s\n", plan.Flow)
+	fmt.Fprintf(tw, "项目:\t%s (%s)\n", plan.Project, plan.Env)
+	fmt.Fprintf(tw, "版本:\t%s\n", plan.Version)
+	for _, field := range [][2]string{{"镜像", plan.Image}, {"作者", plan.Author}, {"分支", plan.Branch}, {"提交", plan.Commit}, {"构建", plan.BuildLink}} {
+		if field[1] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+		}
+	}
+
+	fmt.Fprintln(tw, "\n步骤:")
+	for i, s := range plan.Steps {
+		var notes []string
+		if s.Uses != s.Name {
+			notes = append(notes, "类型 "+s.Uses)
+		}
+		if len(s.Needs) > 0 {
+			notes = append(notes, "依赖 "+strings.Join(s.Needs, ", "))
+		}
+		if s.Timeout > 0 {
+			notes = append(notes, "超时 "+s.Timeout.String())
+		}
+		if s.MaxAttempts > 1 {
+			notes = append(notes, fmt.Sprintf("最多 %d 次", s.MaxAttempts))
+		}
+		if s.ContinueOnError {
+			notes = append(notes, "失败后继续")
+		}
+		status := "执行"
+		if s.Skip {
+			status = "跳过（不满足条件）"
+		}
+		fmt.Fprintf(tw, "  %d. %s\t%s\t%s\n", i+1, s.Name, status, strings.Join(notes, "，"))
+	}
+
+	if len(plan.Targets) > 0 {
+		fmt.Fprintln(tw, "\n目标集群:")
+		for _, t := range plan.Targets {
+			name := t.Name
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t命名空间 %s\n", name, t.Cluster, t.Namespace)
+		}
+	}
+
+	if len(plan.Objects) > 0 {
+		fmt.Fprintln(tw, "\n对象:")
+		for _, o := range plan.Objects {
+			target := ""
+			if o.Target != "" {
+				target = "[" + o.Target + "] "
+			}
+			fmt.Fprintf(tw, "  %s\t%s%s %s/%s\n", o.Action, target, o.Kind, o.Namespace, o.Name)
+		}
+		if !plan.Live {
+			fmt.Fprintln(tw, "  （未访问集群，使用 -dry-run 以服务端 dry-run 区分创建、更新和无变更）")
+			if plan.Prune {
+				fmt.Fprintln(tw, "  （已启用清理，集群中不再属于配置的对象将被删除）")
+			}
+		}
+	}
+
+	fmt.Fprintln(tw, "\n通知:")
+	if len(plan.Notifications) == 0 {
+		fmt.Fprintln(tw, "  无")
+	}
+	for _, n := range plan.Notifications {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", n.Event, n.Channel, n.Type)
+	}
+
+	return tw.Flush()
+}
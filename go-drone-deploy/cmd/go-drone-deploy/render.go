@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kustomizationFile kustomize 基础目录的入口文件名
+const kustomizationFile = "kustomization.yaml"
+
+// writeManifests 以多文档 YAML 输出清单，配置了多集群部署时在每个文档前注明目标集群
+func writeManifests(w io.Writer, manifests []*biz.Manifest) error {
+	for i, m := range manifests {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if m.Target != "" {
+			if _, err := fmt.Fprintf(w, "# target: %s\n", m.Target); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(m.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeKustomizeBase 将清单写为 kustomize 基础目录，每个对象一个文件；
+// 配置了多集群部署时每个目标集群写入以目标名称命名的子目录
+func writeKustomizeBase(dir string, manifests []*biz.Manifest) error {
+	var targets []string
+	byTarget := map[string][]*biz.Manifest{}
+	for _, m := range manifests {
+		if _, ok := byTarget[m.Target]; !ok {
+			targets = append(targets, m.Target)
+		}
+		byTarget[m.Target] = append(byTarget[m.Target], m)
+	}
+
+	for _, target := range targets {
+		if err := writeKustomization(filepath.Join(dir, target), byTarget[target]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeKustomization 将一组清单写入目录并生成 kustomization.yaml
+func writeKustomization(dir string, manifests []*biz.Manifest) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建目录 %s 失败: %w", dir, err)
+	}
+
+	var kustomization strings.Builder
+	kustomization.WriteString("apiVersion: kustomize.config.k8s.io/v1beta1\n")
+	kustomization.WriteString("kind: Kustomization\n")
+	kustomization.WriteString("resources:\n")
+
+	written := map[string]*biz.Manifest{}
+	for _, m := range manifests {
+		name := manifestFileName(m)
+		if other, ok := written[name]; ok {
+			return fmt.Errorf("%s %s/%s 与 %s %s/%s 的文件名 %s 冲突", m.Kind, m.Namespace, m.Name, other.Kind, other.Namespace, other.Name, name)
+		}
+		written[name] = m
+
+		if err := os.WriteFile(filepath.Join(dir, name), m.Content, 0o644); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", name, err)
+		}
+		fmt.Fprintf(&kustomization, "- %s\n", name)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, kustomizationFile), []byte(kustomization.String()), 0o644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", kustomizationFile, err)
+	}
+	return nil
+}
+
+// manifestFileName 返回清单的文件名，由命名空间、类型（非核心 API 组时带组名）和名称组成，
+// 如 default_deployment.apps_demo.yaml、namespace_team-a.yaml
+func manifestFileName(m *biz.Manifest) string {
+	kind := strings.ToLower(m.Kind)
+	if group := schema.FromAPIVersionAndKind(m.APIVersion, m.Kind).Group; group != "" {
+		kind += "." + group
+	}
+
+	parts := []string{kind, m.Name}
+	if m.Namespace != "" {
+		parts = append([]string{m.Namespace}, parts...)
+	}
+	return strings.Join(parts, "_") + ".yaml"
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+)
+
+func TestManifestFileName(t *testing.T) {
+	tests := []struct {
+		manifest *biz.Manifest
+		want     string
+	}{
+		{&biz.Manifest{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "demo"}, "default_deployment.apps_demo.yaml"},
+		{&biz.Manifest{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "demo"}, "default_service_demo.yaml"},
+		{&biz.Manifest{APIVersion: "serving.knative.dev/v1", Kind: "Service", Namespace: "default", Name: "demo"}, "default_service.serving.knative.dev_demo.yaml"},
+		{&biz.Manifest{APIVersion: "v1", Kind: "Namespace", Name: "team-a"}, "namespace_team-a.yaml"},
+	}
+	for _, tt := range tests {
+		if got := manifestFileName(tt.manifest); got != tt.want {
+			t.Errorf("manifestFileName(%s %s) = %s, want %s", tt.manifest.APIVersion, tt.manifest.Kind, got, tt.want)
+		}
+	}
+}
+
+// TestWriteKustomizeBase 同名对象按命名空间区分，多集群时每个目标写入子目录
+func TestWriteKustomizeBase(t *testing.T) {
+	dir := t.TempDir()
+	manifests := []*biz.Manifest{
+		{Target: "a", APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns-1", Name: "config", Content: []byte("a1\n")},
+		{Target: "a", APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns-2", Name: "config", Content: []byte("a2\n")},
+		{Target: "b", APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns-1", Name: "config", Content: []byte("b1\n")},
+	}
+	if err := writeKustomizeBase(dir, manifests); err != nil {
+		t.Fatalf("writeKustomizeBase() error = %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"a/ns-1_configmap_config.yaml": "a1\n",
+		"a/ns-2_configmap_config.yaml": "a2\n",
+		"b/ns-1_configmap_config.yaml": "b1\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil || string(content) != want {
+			t.Errorf("%s = %q, %v, want %q", path, content, err, want)
+		}
+	}
+	kustomization, err := os.ReadFile(filepath.Join(dir, "a", kustomizationFile))
+	if err != nil || !strings.Contains(string(kustomization), "- ns-2_configmap_config.yaml\n") {
+		t.Errorf("a/%s = %q, %v", kustomizationFile, kustomization, err)
+	}
+}
+
+func TestWriteKustomizeBaseCollision(t *testing.T) {
+	manifests := []*biz.Manifest{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "config"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "config"},
+	}
+	if err := writeKustomizeBase(t.TempDir(), manifests); err == nil {
+		t.Error("writeKustomizeBase() error = nil, want 文件名冲突")
+	}
+}
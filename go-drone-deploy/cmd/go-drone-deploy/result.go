@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// resultJSON 部署结果的 JSON 输出
+type resultJSON struct {
+	DeployID    string             `json:"deployId"`
+	Flow        biz.DeployFlow     `json:"flow"`
+	Status      biz.DeployStatus   `json:"status"`
+	StartedAt   time.Time          `json:"startedAt"`
+	FinishedAt  time.Time          `json:"finishedAt"`
+	DurationMs  int64              `json:"durationMs"`
+	Image       string             `json:"image,omitempty"`
+	ImageDigest string             `json:"imageDigest,omitempty"`
+	Steps       []resultStepJSON   `json:"steps"`
+	Revisions   []resultRevJSON    `json:"revisions"`
+	Targets     []resultTargetJSON `json:"targets,omitempty"`
+	Warnings    []string           `json:"warnings"`
+	Error       string             `json:"error,omitempty"`
+}
+
+type resultStepJSON struct {
+	Name       string         `json:"name"`
+	Status     biz.StepStatus `json:"status"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	DurationMs int64          `json:"durationMs"`
+	Attempts   int            `json:"attempts,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type resultRevJSON struct {
+	Target   string `json:"target,omitempty"`
+	Revision int64  `json:"revision"`
+}
+
+type resultTargetJSON struct {
+	Target     string `json:"target"`
+	Namespace  string `json:"namespace"`
+	Skipped    bool   `json:"skipped"`
+	RolledBack bool   `json:"rolledBack"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// errString 返回错误信息，无错误时为空
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// writeResultJSON 以 JSON 输出部署结果
+func writeResultJSON(w io.Writer, result *biz.DeployResult) error {
+	out := resultJSON{
+		DeployID:    result.DeployID,
+		Flow:        result.Flow,
+		Status:      result.Status,
+		StartedAt:   result.StartedAt,
+		FinishedAt:  result.FinishedAt,
+		DurationMs:  result.Duration.Milliseconds(),
+		Image:       result.Image,
+		ImageDigest: result.ImageDigest,
+		Steps:       []resultStepJSON{},
+		Revisions:   []resultRevJSON{},
+		Warnings:    []string{},
+		Error:       errString(result.Err),
+	}
+	for _, s := range result.Steps {
+		out.Steps = append(out.Steps, resultStepJSON{
+			Name:       s.Name,
+			Status:     s.Status,
+			StartedAt:  s.StartedAt,
+			FinishedAt: s.FinishedAt,
+			DurationMs: s.Duration.Milliseconds(),
+			Attempts:   s.Attempts,
+			Error:      errString(s.Err),
+		})
+	}
+	for _, r := range result.Revisions {
+		out.Revisions = append(out.Revisions, resultRevJSON{Target: r.Target, Revision: r.Revision})
+	}
+	for _, t := range result.Targets {
+		out.Targets = append(out.Targets, resultTargetJSON{
+			Target:     t.Target,
+			Namespace:  t.Namespace,
+			Skipped:    t.Skipped,
+			RolledBack: t.RolledBack,
+			DurationMs: t.Duration.Milliseconds(),
+			Error:      errString(t.Err),
+		})
+	}
+	out.Warnings = append(out.Warnings, result.Warnings...)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// writeResult 以表格输出部署结果
+func writeResult(w io.Writer, result *biz.DeployResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "部署 ID:\t%s\n", result.DeployID)
+	fmt.Fprintf(tw, "流程:\t%s\n", result.Flow)
+	fmt.Fprintf(tw, "状态:\t%s\n", result.Status)
+	fmt.Fprintf(tw, "耗时:\t%s\n", result.Duration.Round(time.Millisecond))
+	for _, field := range [][2]string{{"镜像", result.Image}, {"镜像摘要", result.ImageDigest}} {
+		if field[1] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+		}
+	}
+	for _, r := range result.Revisions {
+		target := ""
+		if r.Target != "" {
+			target = " [" + r.Target + "]"
+		}
+		fmt.Fprintf(tw, "Deployment 版本%s:\t%d\n", target, r.Revision)
+	}
+
+	fmt.Fprintln(tw, "\n步骤\t状态\t开始\t结束\t耗时\t次数\t错误")
+	for _, s := range result.Steps {
+		attempts := "-"
+		if s.Attempts > 0 {
+			attempts = fmt.Sprint(s.Attempts)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Status,
+			s.StartedAt.Format(time.TimeOnly), s.FinishedAt.Format(time.TimeOnly),
+			s.Duration.Round(time.Millisecond), attempts, errString(s.Err))
+	}
+
+	if len(result.Targets) > 0 {
+		fmt.Fprintln(tw, "\n目标集群\t命名空间\t跳过\t已回滚\t耗时\t错误")
+		for _, t := range result.Targets {
+			fmt.Fprintf(tw, "%s\t%s\t%v\t%v\t%s\t%s\n", t.Target, t.Namespace, t.Skipped, t.RolledBack,
+				t.Duration.Round(time.Millisecond), errString(t.Err))
+		}
+	}
+
+	if len(result.Warnings) > 0 {
+		fmt.Fprintln(tw, "\n警告:")
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(tw, "  - %s\n", warning)
+		}
+	}
+
+	return tw.Flush()
+}
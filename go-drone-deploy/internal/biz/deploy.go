@@ -3,6 +3,10 @@ package biz
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
 )
@@ -11,11 +15,13 @@ import (
 type DeployFlow string
 
 const (
-	FlowAll      DeployFlow = "all"      // 完整部署流程
-	FlowDocker   DeployFlow = "docker"   // 仅 Docker 构建和推送
-	FlowK8s      DeployFlow = "k8s"      // 仅 Kubernetes 部署
-	FlowNotify   DeployFlow = "notify"   // 仅通知
-	FlowStandard DeployFlow = "standard" // 标准流程（不包含通知）
+	FlowAll      DeployFlow = "all"       // 完整部署流程
+	FlowDocker   DeployFlow = "docker"    // 仅 Docker 构建和推送
+	FlowK8s      DeployFlow = "k8s"       // 仅 Kubernetes 部署
+	FlowNotify   DeployFlow = "notify"    // 仅通知
+	FlowStandard DeployFlow = "standard"  // 标准流程（不包含通知）
+	FlowSetImage DeployFlow = "set-image" // 仅更新 Deployment 镜像
+	FlowRestart  DeployFlow = "restart"   // 滚动重启 Deployment
 )
 
 // DeployConfig 部署配置
@@ -25,9 +31,18 @@ type DeployConfig struct {
 	Namespace   string
 	Version     string
 	Env         string
-	Docker      *DockerConfig
-	K8s         *K8sConfig
-	Notify      *NotifyConfig
+	// Commit 代码提交，BuildLink 构建链接，写入部署元数据注解
+	Commit    string
+	BuildLink string
+	// Branch 代码分支，用于流水线步骤的 when 条件
+	Branch string
+	Docker *DockerConfig
+	K8s    *K8sConfig
+	Notify *NotifyConfig
+	// Pipelines 自定义流水线，按流程名称选择，可覆盖同名的内置流程
+	Pipelines map[DeployFlow]*Pipeline
+	// StepPolicies 按步骤名称或类型设置的超时与重试策略，适用于所有流水线，包括内置流程
+	StepPolicies map[string]*StepPolicy
 }
 
 // DockerConfig Docker 配置
@@ -38,18 +53,250 @@ type DockerConfig struct {
 	ImageName      string
 	DockerfilePath string
 	BuildContext   string
+	// Digest 推送后的镜像摘要，由部署流程填充
+	Digest string
+	// Scan scan 步骤的镜像扫描配置，使用 trivy
+	Scan *ImageScan
+	// Sign sign 步骤的镜像签名配置，使用 cosign
+	Sign *ImageSign
+}
+
+// ImageScan 镜像漏洞扫描配置
+type ImageScan struct {
+	// Severity 导致扫描失败的漏洞等级，默认 HIGH,CRITICAL
+	Severity string
+	// IgnoreUnfixed 忽略尚无修复版本的漏洞
+	IgnoreUnfixed bool
+}
+
+// ImageSign 镜像签名配置
+type ImageSign struct {
+	// Key cosign 私钥路径或 KMS 地址，为空时使用无密钥签名；私钥密码通过 COSIGN_PASSWORD 环境变量提供
+	Key string
 }
 
 // K8sConfig Kubernetes 配置
 type K8sConfig struct {
+	// KubeconfigPath kubeconfig 文件路径，支持 ~ 开头；为空时依次使用 KUBECONFIG 环境变量、~/.kube/config，
+	// 均不存在且运行在集群内时使用 ServiceAccount 认证
 	KubeconfigPath string
+	// Kubeconfig kubeconfig 文件内容，通常来自密钥，优先于 KubeconfigPath
+	Kubeconfig string
+	// Context kubeconfig 中使用的上下文，为空时使用当前上下文
+	Context string
+	// InCluster 强制使用 Pod 的 ServiceAccount 认证，忽略 kubeconfig
+	InCluster bool
+
+	// 覆盖 kubeconfig 中的集群地址与认证信息；未提供 kubeconfig 时可仅通过以下字段连接集群
+	Server                string
+	Token                 string
+	TokenFile             string
+	CAFile                string
+	CAData                string
+	InsecureSkipTLSVerify bool
+
+	// 客户端限流与超时，默认 QPS 50、Burst 100、单次请求超时 30 秒（不影响 watch 及日志跟随）
+	QPS            float32
+	Burst          int
+	RequestTimeout time.Duration
+
 	Namespace      string
 	DeploymentName string
 	ServiceName    string
-	Replicas       int32
-	Resources      *Resources
-	Ports          []*Port
-	EnvVars        []*EnvVar
+	// Image 主容器镜像，为空时使用 Docker 配置中构建的镜像
+	Image string
+	// Version 应用版本，写入 app.kubernetes.io/version 标签，默认为部署版本
+	Version   string
+	Replicas  int32
+	Resources *Resources
+	Ports     []*Port
+	EnvVars   []*EnvVar
+	// VolumeMounts 主容器挂载的卷
+	VolumeMounts []*VolumeMount
+	Autoscaling  *Autoscaling
+	Disruption   *Disruption
+
+	// 附加容器与共享卷；附加容器未指定镜像时使用主容器镜像
+	InitContainers []*Container
+	Sidecars       []*Container
+	Volumes        []*Volume
+
+	// 发布策略与调度
+	Strategy                  *RolloutStrategy
+	NodeSelector              map[string]string
+	Tolerations               []*Toleration
+	Affinity                  *Affinity
+	TopologySpreadConstraints []*TopologySpread
+
+	// 身份与安全
+	ServiceAccountName string
+	ImagePullSecrets   []string
+	SecurityContext    *SecurityContext
+
+	// 标签与注解，选择器始终为 app: DeploymentName，不受影响
+	// AppName app.kubernetes.io/name 标签，默认为项目名称
+	AppName   string
+	Component string
+	PartOf    string
+	// Labels 附加到生成对象及 Pod 的标签，不会覆盖推荐标签与选择器标签
+	Labels map[string]string
+	// Annotations 附加到生成对象的注解
+	Annotations map[string]string
+	// PodAnnotations 附加到 Pod 模板的注解
+	PodAnnotations map[string]string
+	// DeployMeta 部署元数据，写入生成对象的注解，由部署流程自动填充
+	DeployMeta *DeployMeta
+
+	// 额外的原生清单
+	Manifests *Manifests
+
+	// Release 发布实例标识，写入 app.kubernetes.io/instance 标签，默认为 DeploymentName
+	Release string
+	// Prune 清理上次部署写入、本次配置中已不存在的对象
+	Prune bool
+
+	// Hooks 部署前后运行的 Job
+	Hooks []*Hook
+
+	// Bootstrap 命名空间初始化
+	Bootstrap *NamespaceBootstrap
+
+	// 多集群部署目标，为空时只部署到 KubeconfigPath 指定的集群
+	Targets       []*Target
+	Parallel      bool
+	FailurePolicy FailurePolicy
+
+	// RolloutTimeout 等待 Deployment 滚动更新完成的超时时间，默认 5 分钟
+	RolloutTimeout time.Duration
+	// DiagnosticLogLines 部署失败时每个异常容器收集的日志行数，默认 50
+	DiagnosticLogLines int64
+}
+
+// NamespaceBootstrap 命名空间初始化配置，命名空间不存在时创建
+type NamespaceBootstrap struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	// PodSecurity Pod 安全准入级别（privileged/baseline/restricted），写入 pod-security.kubernetes.io 标签
+	PodSecurity string
+	// ResourceQuota 资源配额，如 {"requests.cpu": "4", "limits.memory": "8Gi", "pods": "20"}
+	ResourceQuota map[string]string
+	LimitRange    *LimitRange
+	// ImagePullSecret 由 Docker 仓库凭证生成的镜像拉取 Secret 名称，为空时不创建
+	ImagePullSecret string
+	// Manage 接管命名空间及其资源配额、默认资源限制，每次部署以服务端 apply 更新；
+	// 默认只在不存在时创建，已存在的对象（可能由平台团队或 GitOps 管理）保持不变
+	Manage bool
+}
+
+// LimitRange 容器默认资源与上下限，如 {"cpu": "100m", "memory": "128Mi"}
+type LimitRange struct {
+	Default        map[string]string
+	DefaultRequest map[string]string
+	Max            map[string]string
+	Min            map[string]string
+}
+
+// HookPhase Job 钩子运行阶段
+type HookPhase string
+
+const (
+	HookPreDeploy  HookPhase = "pre-deploy"  // Deployment 更新前运行，失败时中止部署
+	HookPostDeploy HookPhase = "post-deploy" // Deployment 及相关资源更新后运行
+)
+
+// Hook 部署钩子，以 Kubernetes Job 运行，使用与主容器相同的镜像
+type Hook struct {
+	Name    string
+	Phase   HookPhase
+	Command []string
+	Args    []string
+	EnvVars []*EnvVar
+	// Timeout 等待 Job 完成的超时时间，默认 10 分钟
+	Timeout time.Duration
+	// BackoffLimit 失败重试次数，默认 0
+	BackoffLimit int32
+}
+
+// DeployMeta 部署元数据
+type DeployMeta struct {
+	Commit    string
+	BuildLink string
+	Author    string
+	Time      time.Time
+}
+
+// Manifests 用户提供的额外清单（CRD、CronJob、NetworkPolicy 等）
+// 清单内容按 Go 模板渲染，可使用 {{ .Version }}、{{ .Image }} 等部署变量
+type Manifests struct {
+	// Paths YAML/JSON 文件或目录列表，目录按文件名顺序读取；以 .tmpl 结尾的文件（如 cronjob.yaml.tmpl）按 Go 模板渲染
+	Paths []string
+	// Kustomization kustomize 目录，通过 kubectl kustomize 构建，构建结果不按模板渲染
+	Kustomization string
+	// Template 将 Paths 中的全部清单按 Go 模板渲染，而不只是 .tmpl 文件
+	Template bool
+	// Vars 模板变量，内置变量 ProjectName、Env、Version、Image、Namespace 未设置时自动填充
+	Vars map[string]string
+}
+
+// RolloutStrategy 滚动更新策略
+// MaxSurge、MaxUnavailable 取值为整数或百分比，未设置时分别为 "25%" 和 "0"，保证发布期间不减少可用副本
+type RolloutStrategy struct {
+	MaxSurge       string
+	MaxUnavailable string
+}
+
+// Toleration 污点容忍
+type Toleration struct {
+	Key               string
+	Operator          string
+	Value             string
+	Effect            string
+	TolerationSeconds *int64
+}
+
+// Affinity 亲和性配置
+type Affinity struct {
+	// RequiredNodeLabels 要求节点标签取值在给定列表中
+	RequiredNodeLabels map[string][]string
+	// PodAntiAffinity 同一应用 Pod 的反亲和，可选 "preferred"、"required"，按节点打散
+	PodAntiAffinity string
+}
+
+// TopologySpread 拓扑分布约束，作用于当前应用的 Pod
+type TopologySpread struct {
+	TopologyKey       string
+	MaxSkew           int32
+	WhenUnsatisfiable string
+}
+
+// SecurityContext 安全上下文
+// 未设置的字段使用安全默认值：禁止提权、丢弃全部 capabilities、使用 RuntimeDefault seccomp
+type SecurityContext struct {
+	RunAsNonRoot             *bool
+	RunAsUser                *int64
+	RunAsGroup               *int64
+	FSGroup                  *int64
+	ReadOnlyRootFilesystem   *bool
+	AllowPrivilegeEscalation *bool
+	DropCapabilities         []string
+	AddCapabilities          []string
+	SeccompProfile           string
+}
+
+// Autoscaling HorizontalPodAutoscaler 配置
+// 启用后 Deployment 的副本数由 HPA 管理，更新时不再覆盖 spec.replicas
+type Autoscaling struct {
+	MinReplicas                       int32
+	MaxReplicas                       int32
+	TargetCPUUtilizationPercentage    int32
+	TargetMemoryUtilizationPercentage int32
+}
+
+// Disruption PodDisruptionBudget 配置
+// MinAvailable 与 MaxUnavailable 只能设置其一，取值为整数或百分比，如 "1"、"50%"
+type Disruption struct {
+	MinAvailable   string
+	MaxUnavailable string
 }
 
 // Resources 资源配置
@@ -74,122 +321,547 @@ type EnvVar struct {
 	Value string
 }
 
+// Container 附加容器，用作 init 容器或 sidecar
+type Container struct {
+	Name         string
+	Image        string
+	Command      []string
+	Args         []string
+	EnvVars      []*EnvVar
+	Ports        []*Port
+	Resources    *Resources
+	VolumeMounts []*VolumeMount
+}
+
+// Volume Pod 卷，EmptyDir、ConfigMap、Secret、PersistentVolumeClaim 只能设置一个
+type Volume struct {
+	Name                  string
+	EmptyDir              *EmptyDir
+	ConfigMap             string
+	Secret                string
+	PersistentVolumeClaim string
+	// ReadOnly 以只读方式使用 PersistentVolumeClaim
+	ReadOnly bool
+}
+
+// EmptyDir 临时卷
+type EmptyDir struct {
+	// Medium 存储介质，Memory 表示使用内存
+	Medium    string
+	SizeLimit string
+}
+
+// VolumeMount 卷挂载
+type VolumeMount struct {
+	Name      string
+	MountPath string
+	SubPath   string
+	ReadOnly  bool
+}
+
 // NotifyConfig 通知配置
 type NotifyConfig struct {
-	Enabled    bool
+	Enabled bool
+	// 单个通知渠道，兼容原有配置；Type 为空时为 Slack
+	Type       NotifierType
 	WebhookURL string
 	Channel    string
+	Secret     string
+	// Notifiers 更多通知渠道，与上面的单渠道配置一起发送
+	Notifiers []*NotifierConfig
+	// Events 订阅的部署事件，为空时订阅全部事件
+	Events []DeployEvent
+	// Format 单渠道配置的消息格式，默认为纯文本
+	Format MessageFormat
+	// Language 默认模板的语言：zh（默认）、en
+	Language string
+	// Templates 按事件自定义消息模板，未设置的事件使用默认模板
+	Templates map[DeployEvent]*MessageTemplate
+
+	// Timeout 单次发送的超时时间，默认 10 秒
+	Timeout time.Duration
+	// Retry 暂时性错误（网络错误、5xx、429、渠道限流）的重试策略
+	Retry *NotifyRetry
+	// OutboxDir 发件箱目录，重试后仍失败的通知写入此目录，下次部署时补发；为空时不启用
+	OutboxDir string
+	// OutboxMaxAge 发件箱中通知的最长保留时间，默认 24 小时
+	OutboxMaxAge time.Duration
+	// OutboxFlushTimeout 部署开始时补发发件箱的总超时时间，默认 1 分钟
+	OutboxFlushTimeout time.Duration
+
+	// Webhooks 通用 Webhook，推送机器可读的部署事件，与聊天渠道共用重试策略
+	Webhooks []*WebhookConfig
+	// EventDir 部署事件目录，推送的事件保存在此目录，可用 replay 命令重放；为空时不保存
+	EventDir string
+}
+
+// NotifyRetry 通知重试策略，等待时间按指数增长，服务端返回 Retry-After 时优先使用
+type NotifyRetry struct {
+	// MaxAttempts 最多发送次数，默认 3
+	MaxAttempts int
+	// InitialBackoff 首次重试前的等待时间，默认 1 秒
+	InitialBackoff time.Duration
+	// MaxBackoff 单次等待的上限，默认 30 秒
+	MaxBackoff time.Duration
+}
+
+// NotifierType 通知渠道类型
+type NotifierType string
+
+const (
+	NotifierSlack    NotifierType = "slack"
+	NotifierDingTalk NotifierType = "dingtalk"
+	NotifierFeishu   NotifierType = "feishu"
+	NotifierWeCom    NotifierType = "wecom"
+	NotifierEmail    NotifierType = "email"
+)
+
+// NotifierConfig 单个通知渠道配置
+type NotifierConfig struct {
+	// Name 渠道名称，用于日志和错误信息，默认为类型
+	Name       string
+	Type       NotifierType
+	WebhookURL string
+	// Channel Slack 频道
+	Channel string
+	// Secret 钉钉、飞书机器人的签名密钥
+	Secret string
+	// Events 该渠道订阅的部署事件，为空时使用 NotifyConfig.Events
+	Events []DeployEvent
+	// Format 消息格式，默认为纯文本
+	Format MessageFormat
+	// SMTP 邮件渠道配置，Type 为 email 时使用
+	SMTP *SMTPConfig
+}
+
+// SMTPSecurity SMTP 连接加密方式
+type SMTPSecurity string
+
+const (
+	SMTPStartTLS SMTPSecurity = "starttls" // 明文连接后升级为 TLS（默认，通常为 587 端口）
+	SMTPTLS      SMTPSecurity = "tls"      // 直接建立 TLS 连接（通常为 465 端口）
+	SMTPNone     SMTPSecurity = "none"     // 不加密，仅用于本地测试
+)
+
+// SMTPConfig 邮件渠道配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Security SMTPSecurity
+	// InsecureSkipVerify 跳过服务器证书校验
+	InsecureSkipVerify bool
+	// Username 为空时不认证
+	Username string
+	Password string
+	From     string
+	To       []string
+	Cc       []string
+}
+
+// AllNotifiers 返回全部通知渠道，包括单渠道配置
+func (c *NotifyConfig) AllNotifiers() []*NotifierConfig {
+	var notifiers []*NotifierConfig
+	if c.WebhookURL != "" {
+		notifiers = append(notifiers, &NotifierConfig{
+			Type:       c.Type,
+			WebhookURL: c.WebhookURL,
+			Channel:    c.Channel,
+			Secret:     c.Secret,
+			Events:     c.Events,
+			Format:     c.Format,
+		})
+	}
+	return append(notifiers, c.Notifiers...)
+}
+
+// DiffAction 资源变更类型
+type DiffAction string
+
+const (
+	DiffCreate    DiffAction = "create"    // 资源不存在，将被创建
+	DiffUpdate    DiffAction = "update"    // 资源存在且有变更
+	DiffUnchanged DiffAction = "unchanged" // 资源无变更
+	DiffDelete    DiffAction = "delete"    // 资源已从配置中移除，将被清理
+	DiffApply     DiffAction = "apply"     // 未对比集群，将创建或更新
+)
+
+// ResourceRef Kubernetes 资源引用
+type ResourceRef struct {
+	// Target 多集群部署时所属的目标名称
+	Target    string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ResourceDiff 单个资源与集群现状的差异
+type ResourceDiff struct {
+	// Target 多集群部署时所属的目标名称
+	Target    string
+	Kind      string
+	Namespace string
+	Name      string
+	Action    DiffAction
+	// Diff 统一 diff 格式的字段级差异，Secret 的值已脱敏
+	Diff string
+}
+
+// Manifest 渲染后的 Kubernetes 清单
+type Manifest struct {
+	// Target 目标集群名称，未配置多集群部署时为空
+	Target     string
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Content 单个对象的 YAML 内容
+	Content []byte
 }
 
 // DeployRepo 部署仓库接口
 type DeployRepo interface {
 	// Docker 相关
 	BuildDockerImage(ctx context.Context, config *DockerConfig) error
-	PushDockerImage(ctx context.Context, config *DockerConfig) error
-	
+	ScanDockerImage(ctx context.Context, config *DockerConfig) error
+	SignDockerImage(ctx context.Context, config *DockerConfig) error
+	// PushDockerImage 推送镜像，返回镜像摘要（repo@sha256:...），无法获取时为空
+	PushDockerImage(ctx context.Context, config *DockerConfig) (string, error)
+
 	// Kubernetes 相关
 	ApplyK8sDeployment(ctx context.Context, config *K8sConfig) error
 	ApplyK8sService(ctx context.Context, config *K8sConfig) error
-	UpdateK8sVersion(ctx context.Context, config *K8sConfig) error
-	
+	SetK8sImage(ctx context.Context, config *K8sConfig) error
+	RestartK8sDeployment(ctx context.Context, config *K8sConfig) error
+	ApplyK8sAutoscaler(ctx context.Context, config *K8sConfig) error
+	ApplyK8sPodDisruptionBudget(ctx context.Context, config *K8sConfig) error
+	DiffK8s(ctx context.Context, config *K8sConfig, docker *DockerConfig) ([]*ResourceDiff, error)
+	RenderK8s(ctx context.Context, config *K8sConfig) ([]*Manifest, error)
+	ApplyK8sManifests(ctx context.Context, config *K8sConfig) error
+	PruneK8s(ctx context.Context, config *K8sConfig, dryRun bool) ([]*ResourceRef, error)
+	RunK8sHook(ctx context.Context, config *K8sConfig, hook *Hook) error
+	WaitK8sRollout(ctx context.Context, config *K8sConfig) error
+	CollectK8sDiagnostics(ctx context.Context, config *K8sConfig) (*Diagnostics, error)
+	BootstrapK8sNamespace(ctx context.Context, config *K8sConfig, docker *DockerConfig) error
+	RollbackK8sDeployment(ctx context.Context, config *K8sConfig) error
+	// GetK8sRevision 返回 Deployment 当前的版本号，Deployment 不存在时返回 0
+	GetK8sRevision(ctx context.Context, config *K8sConfig) (int64, error)
+
+	// ClassifyError 识别错误类别，用于判断步骤是否重试，无法识别时返回空
+	ClassifyError(err error) ErrorClass
+
 	// 通知相关
-	SendNotification(ctx context.Context, config *NotifyConfig, message string) error
+	SendNotification(ctx context.Context, config *NotifyConfig, message *Message) error
+	// FlushNotificationOutbox 补发发件箱中的通知，返回补发成功的数量
+	FlushNotificationOutbox(ctx context.Context, config *NotifyConfig) (int, error)
+	SendWebhookEvent(ctx context.Context, config *NotifyConfig, webhooks []*WebhookConfig, event *WebhookEvent) error
+	SaveWebhookEvent(ctx context.Context, config *NotifyConfig, event *WebhookEvent) error
+	// LoadWebhookEvents 按部署 ID 或事件 ID 读取已保存的事件，按发生顺序排列
+	LoadWebhookEvents(ctx context.Context, config *NotifyConfig, id string) ([]*WebhookEvent, error)
 }
 
 // DeployUsecase 部署用例
 type DeployUsecase struct {
 	repo DeployRepo
 	log  *log.Helper
+
+	mu sync.RWMutex
+	// steps 已注册的流水线步骤
+	steps map[string]StepFunc
 }
 
 // NewDeployUsecase 创建部署用例
 func NewDeployUsecase(repo DeployRepo, logger log.Logger) *DeployUsecase {
-	return &DeployUsecase{
-		repo: repo,
-		log:  log.NewHelper(logger),
+	uc := &DeployUsecase{
+		repo:  repo,
+		log:   log.NewHelper(logger),
+		steps: map[string]StepFunc{},
 	}
+	uc.registerBuiltinSteps()
+	return uc
 }
 
-// Deploy 执行部署
-func (uc *DeployUsecase) Deploy(ctx context.Context, config *DeployConfig, flow DeployFlow) error {
-	uc.log.WithContext(ctx).Infof("开始部署，项目: %s, 环境: %s, 流程: %s", config.ProjectName, config.Env, flow)
+// Deploy 按流程对应的流水线执行部署，流程可以是内置流程或配置中定义的流水线。
+// 流水线开始执行后，无论成功与否都返回部署结果
+func (uc *DeployUsecase) Deploy(ctx context.Context, config *DeployConfig, flow DeployFlow) (*DeployResult, error) {
+	pipeline, err := uc.pipeline(config, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, run := withDeployRun(ctx, flow, pipeline.events)
+	uc.log.WithContext(ctx).Infof("开始部署，项目: %s, 环境: %s, 流程: %s, 部署 ID: %s", config.ProjectName, config.Env, flow, run.id)
+
+	// 每次部署只补发一次发件箱，notify 步骤不再重复补发
+	if !pipeline.Quiet || pipeline.uses(StepNotify) {
+		uc.flushOutbox(ctx, config)
+	}
+
+	if pipeline.Quiet {
+		err = uc.runPipeline(ctx, config, pipeline)
+		return uc.result(ctx, config, pipeline, run, err), err
+	}
+
+	uc.notifyEvent(ctx, run.notification(EventStarted, config, nil))
 
-	switch flow {
-	case FlowAll:
-		return uc.deployAll(ctx, config)
-	case FlowDocker:
-		return uc.deployDocker(ctx, config)
-	case FlowK8s:
-		return uc.deployK8s(ctx, config)
-	case FlowNotify:
-		return uc.deployNotify(ctx, config)
-	case FlowStandard:
-		return uc.deployStandard(ctx, config)
-	default:
-		return fmt.Errorf("不支持的部署流程: %s", flow)
+	// 部署 Kubernetes 前对比差异，在通知中列出变更的资源
+	var changes []*ResourceDiff
+	if pipeline.uses(StepDeploy, StepApply) && config.Notify != nil && config.Notify.Enabled && config.K8s != nil &&
+		config.Notify.notifies(pipeline.events, EventSucceeded, EventFailed) {
+		changes = uc.changedResources(ctx, config)
 	}
+
+	err = uc.runPipeline(ctx, config, pipeline)
+	result := uc.result(ctx, config, pipeline, run, err)
+
+	n := run.notification(EventSucceeded, config, err)
+	n.Changes = changes
+	if err != nil {
+		n.Event = EventFailed
+	}
+	uc.notifyEvent(ctx, n)
+
+	if rolledBack := rolledBackTargets(err); len(rolledBack) > 0 {
+		n := run.notification(EventRolledBack, config, err)
+		n.RolledBack = rolledBack
+		uc.notifyEvent(ctx, n)
+	}
+
+	// 通知失败的警告在通知发送后才产生
+	result.Warnings = run.Warnings()
+	return result, err
 }
 
-// deployAll 完整部署流程
-func (uc *DeployUsecase) deployAll(ctx context.Context, config *DeployConfig) error {
-	// 1. Docker 构建和推送
-	if err := uc.deployDocker(ctx, config); err != nil {
-		return fmt.Errorf("Docker 部署失败: %w", err)
+// Diff 在集群上以服务端 dry-run 方式应用全部资源，返回与现状的差异，不做任何实际变更
+func (uc *DeployUsecase) Diff(ctx context.Context, config *DeployConfig) ([]*ResourceDiff, error) {
+	if config.K8s == nil {
+		return nil, fmt.Errorf("Kubernetes 配置为空")
 	}
 
-	// 2. Kubernetes 部署
-	if err := uc.deployK8s(ctx, config); err != nil {
-		return fmt.Errorf("Kubernetes 部署失败: %w", err)
+	uc.log.WithContext(ctx).Infof("开始对比集群差异，项目: %s, 环境: %s", config.ProjectName, config.Env)
+	var diffs []*ResourceDiff
+	for _, tc := range uc.targetConfigs(config) {
+		targetDiffs, err := uc.repo.DiffK8s(ctx, tc.config.K8s, tc.config.Docker)
+		if err != nil {
+			return nil, fmt.Errorf("对比 Kubernetes 资源失败%s: %w", tc.label(), err)
+		}
+		for _, d := range targetDiffs {
+			d.Target = tc.name
+		}
+		diffs = append(diffs, targetDiffs...)
+	}
+
+	return diffs, nil
+}
+
+// Render 渲染全部 Kubernetes 资源清单，不访问集群；配置了多集群部署时渲染每个目标集群
+func (uc *DeployUsecase) Render(ctx context.Context, config *DeployConfig) ([]*Manifest, error) {
+	if config.K8s == nil {
+		return nil, fmt.Errorf("Kubernetes 配置为空")
 	}
 
-	// 3. 发送通知
-	if err := uc.deployNotify(ctx, config); err != nil {
-		uc.log.WithContext(ctx).Warnf("通知发送失败: %v", err)
+	// 渲染结果用于 GitOps，不写入部署时间，相同的配置总是得到相同的输出
+	config = uc.resolveConfig(config, time.Time{})
+	var manifests []*Manifest
+	for _, tc := range uc.targetConfigs(config) {
+		targetManifests, err := uc.repo.RenderK8s(ctx, tc.config.K8s)
+		if err != nil {
+			return nil, fmt.Errorf("渲染 Kubernetes 资源失败%s: %w", tc.label(), err)
+		}
+		for _, m := range targetManifests {
+			m.Target = tc.name
+		}
+		manifests = append(manifests, targetManifests...)
 	}
 
-	return nil
+	return manifests, nil
 }
 
-// deployStandard 标准部署流程（不包含通知）
-func (uc *DeployUsecase) deployStandard(ctx context.Context, config *DeployConfig) error {
-	// 1. Docker 构建和推送
-	if err := uc.deployDocker(ctx, config); err != nil {
-		return fmt.Errorf("Docker 部署失败: %w", err)
+// Prune 清理已从配置中移除的托管对象，dryRun 时仅返回将被清理的对象
+func (uc *DeployUsecase) Prune(ctx context.Context, config *DeployConfig, dryRun bool) ([]*ResourceRef, error) {
+	if config.K8s == nil {
+		return nil, fmt.Errorf("Kubernetes 配置为空")
 	}
 
-	// 2. Kubernetes 部署
-	if err := uc.deployK8s(ctx, config); err != nil {
-		return fmt.Errorf("Kubernetes 部署失败: %w", err)
+	uc.log.WithContext(ctx).Infof("开始清理 Kubernetes 资源，项目: %s, dry-run: %v", config.ProjectName, dryRun)
+	var pruned []*ResourceRef
+	for _, tc := range uc.targetConfigs(config) {
+		refs, err := uc.repo.PruneK8s(ctx, tc.config.K8s, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("清理 Kubernetes 资源失败%s: %w", tc.label(), err)
+		}
+		for _, ref := range refs {
+			ref.Target = tc.name
+		}
+		pruned = append(pruned, refs...)
 	}
 
-	return nil
+	return pruned, nil
 }
 
-// deployDocker Docker 构建和推送
-func (uc *DeployUsecase) deployDocker(ctx context.Context, config *DeployConfig) error {
-	if config.Docker == nil {
-		return fmt.Errorf("Docker 配置为空")
+// prepareK8s 返回补全了由部署配置派生字段的副本，不修改传入的配置，
+// 因此并行执行的步骤可以共享同一份配置
+func (uc *DeployUsecase) prepareK8s(config *DeployConfig) *DeployConfig {
+	prepared := *config
+	k8s := *config.K8s
+	prepared.K8s = &k8s
+
+	// 未显式指定镜像时使用 Docker 构建的镜像
+	if k8s.Image == "" && config.Docker != nil {
+		k8s.Image = config.Docker.ImageName
 	}
 
-	uc.log.WithContext(ctx).Info("开始构建 Docker 镜像")
-	if err := uc.repo.BuildDockerImage(ctx, config.Docker); err != nil {
-		return fmt.Errorf("构建 Docker 镜像失败: %w", err)
+	if k8s.Version == "" {
+		k8s.Version = config.Version
+	}
+	if k8s.AppName == "" {
+		k8s.AppName = config.ProjectName
+	}
+	if k8s.DeployMeta == nil {
+		k8s.DeployMeta = newDeployMeta(config, time.Now())
 	}
 
-	uc.log.WithContext(ctx).Info("开始推送 Docker 镜像")
-	if err := uc.repo.PushDockerImage(ctx, config.Docker); err != nil {
-		return fmt.Errorf("推送 Docker 镜像失败: %w", err)
+	// 使用初始化时生成的镜像拉取 Secret
+	if b := k8s.Bootstrap; b != nil && b.ImagePullSecret != "" && !slices.Contains(k8s.ImagePullSecrets, b.ImagePullSecret) {
+		k8s.ImagePullSecrets = append(slices.Clone(k8s.ImagePullSecrets), b.ImagePullSecret)
 	}
 
-	return nil
+	// 填充清单模板的内置变量
+	if m := k8s.Manifests; m != nil {
+		manifests := *m
+		manifests.Vars = map[string]string{
+			"ProjectName": config.ProjectName,
+			"Env":         config.Env,
+			"Version":     config.Version,
+			"Image":       k8s.Image,
+			"Namespace":   k8s.Namespace,
+		}
+		maps.Copy(manifests.Vars, m.Vars)
+		k8s.Manifests = &manifests
+	}
+
+	return &prepared
+}
+
+// newDeployMeta 生成部署元数据
+func newDeployMeta(config *DeployConfig, at time.Time) *DeployMeta {
+	return &DeployMeta{
+		Commit:    config.Commit,
+		BuildLink: config.BuildLink,
+		Author:    config.Author,
+		Time:      at,
+	}
+}
+
+// resolveConfig 生成一次部署使用的配置副本，所有步骤及目标集群使用相同的部署时间；
+// 步骤之间共享该副本，步骤不得修改
+func (uc *DeployUsecase) resolveConfig(config *DeployConfig, startedAt time.Time) *DeployConfig {
+	resolved := *config
+	if config.K8s != nil && config.K8s.DeployMeta == nil {
+		k8s := *config.K8s
+		k8s.DeployMeta = newDeployMeta(config, startedAt)
+		resolved.K8s = &k8s
+	}
+	return &resolved
+}
+
+// HasChanges 判断差异中是否存在需要变更的资源
+func HasChanges(diffs []*ResourceDiff) bool {
+	for _, d := range diffs {
+		if d.Action != DiffUnchanged {
+			return true
+		}
+	}
+	return false
 }
 
-// deployK8s Kubernetes 部署
+// deployK8s Kubernetes 部署，配置了多个目标时部署到每个目标集群
 func (uc *DeployUsecase) deployK8s(ctx context.Context, config *DeployConfig) error {
 	if config.K8s == nil {
 		return fmt.Errorf("Kubernetes 配置为空")
 	}
 
+	if len(config.K8s.Targets) > 0 {
+		return uc.deployTargets(ctx, config)
+	}
+	return uc.deployCluster(ctx, uc.prepareK8s(config))
+}
+
+// deployCluster 部署到单个集群，失败时附带集群诊断信息；配置须已由 prepareK8s 补全
+func (uc *DeployUsecase) deployCluster(ctx context.Context, config *DeployConfig) error {
+	err := uc.applyK8s(ctx, config)
+	if err == nil {
+		return nil
+	}
+
+	// 部署可能因超时或取消失败，诊断使用独立的上下文
+	diagCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticsTimeout)
+	defer cancel()
+	uc.log.WithContext(ctx).Info("部署失败，开始收集 Kubernetes 诊断信息")
+	diagnostics, diagErr := uc.repo.CollectK8sDiagnostics(diagCtx, config.K8s)
+	if diagErr != nil {
+		uc.log.WithContext(ctx).Warnf("收集诊断信息失败: %v", diagErr)
+		return err
+	}
+
+	return &DeployError{Err: err, Diagnostics: diagnostics}
+}
+
+// applyK8s 依次应用 Kubernetes 资源并等待发布完成
+func (uc *DeployUsecase) applyK8s(ctx context.Context, config *DeployConfig) error {
+	if err := uc.applyDependencies(ctx, config); err != nil {
+		return err
+	}
+
+	// 前置钩子失败时中止部署，Deployment 保持不变
+	if err := uc.runHooks(ctx, config.K8s, HookPreDeploy); err != nil {
+		return err
+	}
+
+	if err := uc.applyResources(ctx, config); err != nil {
+		return err
+	}
+
+	uc.log.WithContext(ctx).Info("等待 Kubernetes Deployment 发布完成")
+	if err := uc.repo.WaitK8sRollout(ctx, config.K8s); err != nil {
+		return fmt.Errorf("Kubernetes Deployment 发布失败: %w", err)
+	}
+
+	if err := uc.runHooks(ctx, config.K8s, HookPostDeploy); err != nil {
+		return err
+	}
+
+	if config.K8s.Prune {
+		uc.log.WithContext(ctx).Info("开始清理已移除的 Kubernetes 资源")
+		if _, err := uc.repo.PruneK8s(ctx, config.K8s, false); err != nil {
+			return fmt.Errorf("清理 Kubernetes 资源失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyDependencies 初始化命名空间并应用额外清单
+func (uc *DeployUsecase) applyDependencies(ctx context.Context, config *DeployConfig) error {
+	if config.K8s.Bootstrap != nil {
+		uc.log.WithContext(ctx).Info("开始初始化 Kubernetes 命名空间")
+		if err := uc.repo.BootstrapK8sNamespace(ctx, config.K8s, config.Docker); err != nil {
+			return fmt.Errorf("初始化 Kubernetes 命名空间失败: %w", err)
+		}
+	}
+
+	// 额外清单可能包含 Namespace、CRD、ConfigMap 等依赖，先于 Deployment 应用
+	if config.K8s.Manifests != nil {
+		uc.log.WithContext(ctx).Info("开始应用额外 Kubernetes 清单")
+		if err := uc.repo.ApplyK8sManifests(ctx, config.K8s); err != nil {
+			return fmt.Errorf("应用额外 Kubernetes 清单失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyResources 应用 Deployment、Service 及可选的 HPA、PDB
+func (uc *DeployUsecase) applyResources(ctx context.Context, config *DeployConfig) error {
 	uc.log.WithContext(ctx).Info("开始部署 Kubernetes Deployment")
 	if err := uc.repo.ApplyK8sDeployment(ctx, config.K8s); err != nil {
 		return fmt.Errorf("部署 Kubernetes Deployment 失败: %w", err)
@@ -200,21 +872,71 @@ func (uc *DeployUsecase) deployK8s(ctx context.Context, config *DeployConfig) er
 		return fmt.Errorf("部署 Kubernetes Service 失败: %w", err)
 	}
 
-	uc.log.WithContext(ctx).Info("开始更新 Kubernetes 版本")
-	if err := uc.repo.UpdateK8sVersion(ctx, config.K8s); err != nil {
-		return fmt.Errorf("更新 Kubernetes 版本失败: %w", err)
+	if config.K8s.Autoscaling != nil {
+		uc.log.WithContext(ctx).Info("开始部署 Kubernetes HorizontalPodAutoscaler")
+		if err := uc.repo.ApplyK8sAutoscaler(ctx, config.K8s); err != nil {
+			return fmt.Errorf("部署 Kubernetes HorizontalPodAutoscaler 失败: %w", err)
+		}
+	}
+
+	if config.K8s.Disruption != nil {
+		uc.log.WithContext(ctx).Info("开始部署 Kubernetes PodDisruptionBudget")
+		if err := uc.repo.ApplyK8sPodDisruptionBudget(ctx, config.K8s); err != nil {
+			return fmt.Errorf("部署 Kubernetes PodDisruptionBudget 失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rolloutK8s 对每个目标集群的 Deployment 执行单项变更（更新镜像、重启）并等待发布完成
+func (uc *DeployUsecase) rolloutK8s(ctx context.Context, config *DeployConfig, action string,
+	change func(ctx context.Context, config *K8sConfig) error) error {
+	if config.K8s == nil {
+		return fmt.Errorf("Kubernetes 配置为空")
+	}
+
+	for _, tc := range uc.targetConfigs(config) {
+		uc.log.WithContext(ctx).Infof("开始%s Kubernetes Deployment%s", action, tc.label())
+		if err := change(ctx, tc.config.K8s); err != nil {
+			return fmt.Errorf("%s Kubernetes Deployment 失败%s: %w", action, tc.label(), err)
+		}
+
+		uc.log.WithContext(ctx).Info("等待 Kubernetes Deployment 发布完成")
+		if err := uc.repo.WaitK8sRollout(ctx, tc.config.K8s); err != nil {
+			return fmt.Errorf("Kubernetes Deployment 发布失败%s: %w", tc.label(), err)
+		}
 	}
 
 	return nil
 }
 
-// deployNotify 发送通知
+// runHooks 依次运行指定阶段的钩子，任一失败即返回
+func (uc *DeployUsecase) runHooks(ctx context.Context, config *K8sConfig, phase HookPhase) error {
+	for _, hook := range config.Hooks {
+		if hook.Phase != phase {
+			continue
+		}
+
+		uc.log.WithContext(ctx).Infof("开始运行 %s 钩子: %s", phase, hook.Name)
+		if err := uc.repo.RunK8sHook(ctx, config, hook); err != nil {
+			return fmt.Errorf("%s 钩子 %s 失败: %w", phase, hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// deployNotify 发送部署成功通知，未配置订阅事件的渠道同样接收
 func (uc *DeployUsecase) deployNotify(ctx context.Context, config *DeployConfig) error {
 	if config.Notify == nil || !config.Notify.Enabled {
 		uc.log.WithContext(ctx).Info("通知功能未启用")
 		return nil
 	}
 
-	message := fmt.Sprintf("项目 %s 在 %s 环境部署成功，版本: %s", config.ProjectName, config.Env, config.Version)
-	return uc.repo.SendNotification(ctx, config.Notify, message)
-}
\ No newline at end of file
+	n := &Notification{Event: EventSucceeded, Flow: FlowNotify, Config: config}
+	if run := runFrom(ctx); run != nil {
+		n = run.notification(EventSucceeded, config, nil)
+	}
+	n.defaults = []DeployEvent{EventSucceeded}
+	return uc.notify(ctx, n)
+}
@@ -0,0 +1,92 @@
+package biz
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// diagnosticsTimeout 收集诊断信息的超时时间
+const diagnosticsTimeout = 30 * time.Second
+
+// DeployError 部署失败错误，附带集群诊断信息
+type DeployError struct {
+	Err         error
+	Diagnostics *Diagnostics
+}
+
+func (e *DeployError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeployError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostics 部署失败时收集的诊断信息
+type Diagnostics struct {
+	// Events Deployment、ReplicaSet、Pod 的近期事件，按时间排序
+	Events []*DiagnosticEvent
+	// Containers 异常容器的状态及日志
+	Containers []*ContainerDiagnosis
+}
+
+// DiagnosticEvent Kubernetes 事件
+type DiagnosticEvent struct {
+	Time    time.Time
+	Type    string
+	Object  string
+	Reason  string
+	Message string
+}
+
+// ContainerDiagnosis 异常容器的状态
+type ContainerDiagnosis struct {
+	Pod          string
+	Container    string
+	Reason       string // 如 CrashLoopBackOff、ImagePullBackOff、OOMKilled
+	Message      string
+	ExitCode     int32
+	RestartCount int32
+	// Logs 容器最后若干行日志，容器重启过时取上一次运行的日志
+	Logs string
+}
+
+// Summary 生成可读的诊断摘要
+func (d *Diagnostics) Summary() string {
+	if d == nil || (len(d.Events) == 0 && len(d.Containers) == 0) {
+		return "未发现异常事件或容器"
+	}
+
+	var b strings.Builder
+	if len(d.Containers) > 0 {
+		b.WriteString("异常容器:\n")
+		for _, c := range d.Containers {
+			fmt.Fprintf(&b, "  - %s/%s: %s", c.Pod, c.Container, c.Reason)
+			if c.ExitCode != 0 {
+				fmt.Fprintf(&b, " (退出码 %d)", c.ExitCode)
+			}
+			if c.RestartCount > 0 {
+				fmt.Fprintf(&b, "，已重启 %d 次", c.RestartCount)
+			}
+			if c.Message != "" {
+				fmt.Fprintf(&b, "，%s", c.Message)
+			}
+			b.WriteString("\n")
+			if c.Logs != "" {
+				for _, line := range strings.Split(strings.TrimRight(c.Logs, "\n"), "\n") {
+					fmt.Fprintf(&b, "      | %s\n", line)
+				}
+			}
+		}
+	}
+
+	if len(d.Events) > 0 {
+		b.WriteString("近期事件:\n")
+		for _, e := range d.Events {
+			fmt.Fprintf(&b, "  %s %-7s %s %s: %s\n", e.Time.Format("15:04:05"), e.Type, e.Object, e.Reason, e.Message)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,153 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WebhookFormat 部署事件的数据格式
+type WebhookFormat string
+
+const (
+	WebhookJSON        WebhookFormat = "json"        // 版本化的 JSON（默认）
+	WebhookCloudEvents WebhookFormat = "cloudevents" // CloudEvents 1.0 结构化格式
+)
+
+// WebhookConfig 通用 Webhook，以机器可读的格式推送部署事件，供发布看板等系统使用
+type WebhookConfig struct {
+	// Name 名称，用于日志和错误信息，默认为 URL 的主机名
+	Name string
+	URL  string
+	// Secret HMAC-SHA256 签名密钥，配置后请求附带签名头
+	Secret string
+	// Events 订阅的部署事件，为空时使用 NotifyConfig.Events
+	Events []DeployEvent
+	Format WebhookFormat
+	// Headers 附加的请求头
+	Headers map[string]string
+}
+
+// WebhookEvent 部署生命周期事件
+type WebhookEvent struct {
+	// ID 事件标识，重放时保持不变，接收方可据此去重
+	ID       string
+	Type     DeployEvent
+	Time     time.Time
+	DeployID string
+	Flow     DeployFlow
+
+	Service     string
+	Env         string
+	Version     string
+	Image       string
+	ImageDigest string
+	Author      string
+	Commit      string
+	BuildLink   string
+
+	StartedAt time.Time
+	// Duration 部署耗时，开始事件为零
+	Duration   time.Duration
+	Steps      []*StepResult
+	Targets    []*TargetResult
+	Error      string
+	RolledBack []string
+	// Replay 是否为重放的事件
+	Replay bool
+}
+
+// subscribedWebhooks 返回订阅了事件的 Webhook
+func (c *NotifyConfig) subscribedWebhooks(event DeployEvent) []*WebhookConfig {
+	var webhooks []*WebhookConfig
+	for _, w := range c.Webhooks {
+		events := w.Events
+		if len(events) == 0 {
+			events = c.Events
+		}
+		if subscribes(events, event) {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks
+}
+
+// webhookEvent 由通知生成部署事件
+func (uc *DeployUsecase) webhookEvent(n *Notification) *WebhookEvent {
+	config := n.Config
+	event := &WebhookEvent{
+		ID:         newID(),
+		Type:       n.Event,
+		Time:       time.Now(),
+		DeployID:   n.DeployID,
+		Flow:       n.Flow,
+		Service:    config.ProjectName,
+		Env:        config.Env,
+		Version:    config.Version,
+		Author:     config.Author,
+		Commit:     config.Commit,
+		BuildLink:  config.BuildLink,
+		StartedAt:  n.StartedAt,
+		Duration:   n.Duration,
+		Steps:      n.Steps,
+		RolledBack: n.RolledBack,
+	}
+	if config.Docker != nil {
+		event.Image = config.Docker.ImageName
+		event.ImageDigest = n.imageDigest()
+	}
+	if n.Err != nil {
+		event.Error = n.Err.Error()
+	}
+	var te *TargetsError
+	if errors.As(n.Err, &te) {
+		event.Targets = te.Results
+	}
+	return event
+}
+
+// sendWebhooks 记录事件并推送到订阅了事件的 Webhook
+func (uc *DeployUsecase) sendWebhooks(ctx context.Context, config *NotifyConfig, webhooks []*WebhookConfig, event *WebhookEvent) error {
+	if config.EventDir != "" {
+		if err := uc.repo.SaveWebhookEvent(ctx, config, event); err != nil {
+			uc.warn(ctx, "记录部署事件失败，该事件无法重放: %v", err)
+		}
+	}
+	return uc.repo.SendWebhookEvent(ctx, config, webhooks, event)
+}
+
+// Replay 重放已记录的部署事件，id 为部署 ID 或事件 ID；事件按当前配置的订阅发送
+func (uc *DeployUsecase) Replay(ctx context.Context, config *DeployConfig, id string) (int, error) {
+	notify := config.Notify
+	if notify == nil || notify.EventDir == "" {
+		return 0, fmt.Errorf("未配置部署事件目录")
+	}
+
+	events, err := uc.repo.LoadWebhookEvents(ctx, notify, id)
+	if err != nil {
+		return 0, fmt.Errorf("读取部署事件失败: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, fmt.Errorf("未找到部署事件: %s", id)
+	}
+
+	sent := 0
+	var errs []error
+	for _, event := range events {
+		webhooks := notify.subscribedWebhooks(event.Type)
+		if len(webhooks) == 0 {
+			uc.log.WithContext(ctx).Infof("没有 Webhook 订阅 %s 事件，跳过: %s", event.Type, event.ID)
+			continue
+		}
+
+		uc.log.WithContext(ctx).Infof("重放部署事件: %s %s (部署 %s)", event.Type, event.ID, event.DeployID)
+		event.Replay = true
+		if err := uc.repo.SendWebhookEvent(ctx, notify, webhooks, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", event.ID, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
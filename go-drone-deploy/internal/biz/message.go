@@ -0,0 +1,211 @@
+package biz
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MessageFormat 通知消息格式
+type MessageFormat string
+
+const (
+	MessageText MessageFormat = "text" // 纯文本（默认）
+	MessageRich MessageFormat = "rich" // 渠道富文本：Slack Block Kit、钉钉 markdown/actionCard、飞书卡片、企业微信 markdown
+)
+
+// Message 渲染后的通知消息，由通知渠道按格式发送
+type Message struct {
+	Event DeployEvent
+	Title string
+	Text  string
+	// Link 构建链接，富文本消息中显示为按钮
+	Link     string
+	LinkText string
+}
+
+// MessageTemplate 通知消息模板，使用 Go text/template 语法，数据为 MessageData
+type MessageTemplate struct {
+	Title string
+	Text  string
+}
+
+// MessageData 通知模板可使用的数据
+type MessageData struct {
+	Event       DeployEvent
+	Flow        DeployFlow
+	Project     string
+	Env         string
+	Version     string
+	Image       string
+	ImageDigest string
+	Author      string
+	Commit      string
+	BuildLink   string
+	// Duration 部署耗时，已取整到秒
+	Duration time.Duration
+	// Changes 本次部署变更的资源
+	Changes []*ResourceDiff
+	// Error 错误信息，Summary 多集群结果或诊断摘要
+	Error   string
+	Summary string
+	// RolledBack 已回滚的目标集群
+	RolledBack []string
+}
+
+// defaultTemplates 各语言的默认消息模板
+var defaultTemplates = map[string]map[DeployEvent]*MessageTemplate{
+	"zh": {
+		EventStarted: {
+			Title: "开始部署: {{.Project}} ({{.Env}})",
+			Text:  "项目 {{.Project}} 开始在 {{.Env}} 环境部署，版本: {{.Version}}" + zhDetails,
+		},
+		EventSucceeded: {
+			Title: "部署成功: {{.Project}} ({{.Env}})",
+			Text:  "项目 {{.Project}} 在 {{.Env}} 环境部署成功，版本: {{.Version}}{{if .Duration}}，耗时 {{.Duration}}{{end}}" + zhDetails + zhChanges,
+		},
+		EventFailed: {
+			Title: "部署失败: {{.Project}} ({{.Env}})",
+			Text:  "项目 {{.Project}} 在 {{.Env}} 环境部署失败，版本: {{.Version}}\n错误: {{.Error}}{{if .Summary}}\n{{.Summary}}{{end}}" + zhDetails,
+		},
+		EventRolledBack: {
+			Title: "部署已回滚: {{.Project}} ({{.Env}})",
+			Text:  "项目 {{.Project}} 在 {{.Env}} 环境部署失败，已回滚目标集群: {{join .RolledBack \", \"}}，版本: {{.Version}}" + zhDetails,
+		},
+	},
+	"en": {
+		EventStarted: {
+			Title: "Deploy started: {{.Project}} ({{.Env}})",
+			Text:  "Deploying {{.Project}} version {{.Version}} to {{.Env}}" + enDetails,
+		},
+		EventSucceeded: {
+			Title: "Deploy succeeded: {{.Project}} ({{.Env}})",
+			Text:  "Deployed {{.Project}} version {{.Version}} to {{.Env}}{{if .Duration}} in {{.Duration}}{{end}}" + enDetails + enChanges,
+		},
+		EventFailed: {
+			Title: "Deploy failed: {{.Project}} ({{.Env}})",
+			Text:  "Failed to deploy {{.Project}} version {{.Version}} to {{.Env}}\nError: {{.Error}}{{if .Summary}}\n{{.Summary}}{{end}}" + enDetails,
+		},
+		EventRolledBack: {
+			Title: "Deploy rolled back: {{.Project}} ({{.Env}})",
+			Text:  "Deploy of {{.Project}} version {{.Version}} to {{.Env}} failed, rolled back targets: {{join .RolledBack \", \"}}" + enDetails,
+		},
+	},
+}
+
+// 默认模板中的公共片段
+const (
+	zhDetails = "{{if .ImageDigest}}\n镜像: {{.ImageDigest}}{{else if .Image}}\n镜像: {{.Image}}{{end}}" +
+		"{{if .Commit}}\n提交: {{short .Commit}}{{end}}{{if .Author}}\n作者: {{.Author}}{{end}}"
+	zhChanges = "{{if .Changes}}\n变更资源:{{range .Changes}}\n- {{if .Target}}[{{.Target}}] {{end}}{{.Kind}} {{.Namespace}}/{{.Name}} ({{.Action}}){{end}}{{end}}"
+	enDetails = "{{if .ImageDigest}}\nImage: {{.ImageDigest}}{{else if .Image}}\nImage: {{.Image}}{{end}}" +
+		"{{if .Commit}}\nCommit: {{short .Commit}}{{end}}{{if .Author}}\nAuthor: {{.Author}}{{end}}"
+	enChanges = "{{if .Changes}}\nChanged resources:{{range .Changes}}\n- {{if .Target}}[{{.Target}}] {{end}}{{.Kind}} {{.Namespace}}/{{.Name}} ({{.Action}}){{end}}{{end}}"
+)
+
+// linkTexts 构建链接按钮的文字
+var linkTexts = map[string]string{
+	"zh": "查看构建",
+	"en": "View build",
+}
+
+// templateFuncs 模板可用的函数
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// short 截取提交哈希的前 8 位
+	"short": func(s string) string {
+		if len(s) > 8 {
+			return s[:8]
+		}
+		return s
+	},
+}
+
+// language 返回消息语言，默认中文
+func (c *NotifyConfig) language() string {
+	if _, ok := defaultTemplates[c.Language]; ok {
+		return c.Language
+	}
+	return "zh"
+}
+
+// messageData 生成模板数据
+func (n *Notification) messageData() *MessageData {
+	c := n.Config
+	data := &MessageData{
+		Event:      n.Event,
+		Flow:       n.Flow,
+		Project:    c.ProjectName,
+		Env:        c.Env,
+		Version:    c.Version,
+		Author:     c.Author,
+		Commit:     c.Commit,
+		BuildLink:  c.BuildLink,
+		Duration:   n.Duration.Round(time.Second),
+		Changes:    n.Changes,
+		RolledBack: n.RolledBack,
+	}
+	if c.Docker != nil {
+		data.Image = c.Docker.ImageName
+		data.ImageDigest = n.imageDigest()
+	}
+	if c.K8s != nil && c.K8s.Image != "" {
+		data.Image = c.K8s.Image
+	}
+	if n.Err != nil {
+		data.Error = n.Err.Error()
+		data.Summary = errorSummary(n.Err)
+	}
+	return data
+}
+
+// renderMessage 按配置的模板渲染通知消息，自定义模板出错时使用默认模板
+func (uc *DeployUsecase) renderMessage(config *NotifyConfig, n *Notification) *Message {
+	lang := config.language()
+	defaults := defaultTemplates[lang][n.Event]
+	data := n.messageData()
+
+	render := func(name, custom, fallback string) string {
+		if custom != "" {
+			text, err := renderTemplate(name, custom, data)
+			if err == nil {
+				return text
+			}
+			uc.log.Warnf("渲染通知模板 %s 失败，使用默认模板: %v", name, err)
+		}
+		text, err := renderTemplate(name, fallback, data)
+		if err != nil {
+			uc.log.Warnf("渲染默认通知模板 %s 失败: %v", name, err)
+		}
+		return text
+	}
+
+	custom := config.Templates[n.Event]
+	if custom == nil {
+		custom = &MessageTemplate{}
+	}
+	return &Message{
+		Event:    n.Event,
+		Title:    render(string(n.Event)+".title", custom.Title, defaults.Title),
+		Text:     render(string(n.Event)+".text", custom.Text, defaults.Text),
+		Link:     data.BuildLink,
+		LinkText: linkTexts[lang],
+	}
+}
+
+// renderTemplate 渲染单个模板
+func renderTemplate(name, text string, data *MessageData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析模板失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("执行模板失败: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
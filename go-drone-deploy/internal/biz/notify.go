@@ -0,0 +1,203 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// DeployEvent 部署生命周期事件
+type DeployEvent string
+
+const (
+	EventStarted    DeployEvent = "started"     // 开始部署
+	EventSucceeded  DeployEvent = "succeeded"   // 部署成功
+	EventFailed     DeployEvent = "failed"      // 部署失败
+	EventRolledBack DeployEvent = "rolled_back" // 部署失败后已回滚
+)
+
+// Notification 部署事件通知
+type Notification struct {
+	Event    DeployEvent
+	Flow     DeployFlow
+	Config   *DeployConfig
+	Err      error
+	Duration time.Duration
+	// Changes 本次部署变更的资源
+	Changes []*ResourceDiff
+	// RolledBack 已回滚的目标集群
+	RolledBack []string
+	// DeployID 部署标识，StartedAt 部署开始时间，Steps 已执行的步骤
+	DeployID  string
+	StartedAt time.Time
+	Steps     []*StepResult
+	// ImageDigest 本次部署推送的镜像摘要
+	ImageDigest string
+
+	// defaults 未配置订阅事件的通知渠道默认接收的事件
+	defaults []DeployEvent
+}
+
+// imageDigest 返回镜像摘要，优先使用本次部署推送得到的摘要
+func (n *Notification) imageDigest() string {
+	if n.ImageDigest != "" {
+		return n.ImageDigest
+	}
+	if n.Config.Docker != nil {
+		return n.Config.Docker.Digest
+	}
+	return ""
+}
+
+// notification 生成本次部署的事件通知，结束事件附带耗时
+func (r *deployRun) notification(event DeployEvent, config *DeployConfig, err error) *Notification {
+	n := &Notification{
+		Event:       event,
+		Flow:        r.flow,
+		Config:      config,
+		Err:         err,
+		DeployID:    r.id,
+		StartedAt:   r.startedAt,
+		Steps:       r.Steps(),
+		ImageDigest: r.Digest(),
+		defaults:    r.events,
+	}
+	if event != EventStarted {
+		n.Duration = time.Since(r.startedAt)
+	}
+	return n
+}
+
+// errorSummary 返回部署错误附带的多集群结果或诊断摘要
+func errorSummary(err error) string {
+	var te *TargetsError
+	var de *DeployError
+	switch {
+	case errors.As(err, &te):
+		return te.Summary()
+	case errors.As(err, &de) && de.Diagnostics != nil:
+		return de.Diagnostics.Summary()
+	}
+	return ""
+}
+
+// rolledBackTargets 返回已回滚的目标集群名称
+func rolledBackTargets(err error) []string {
+	var te *TargetsError
+	if !errors.As(err, &te) {
+		return nil
+	}
+	var names []string
+	for _, r := range te.Results {
+		if r.RolledBack {
+			names = append(names, r.Target)
+		}
+	}
+	return names
+}
+
+// subscribes 判断是否订阅了事件，未配置事件时订阅全部
+func subscribes(events []DeployEvent, event DeployEvent) bool {
+	return len(events) == 0 || slices.Contains(events, event)
+}
+
+// subscribed 返回订阅了事件的通知配置，没有渠道订阅时返回 nil。
+// 生命周期通知需显式订阅：未配置事件的渠道只接收 defaults 中的事件
+func (c *NotifyConfig) subscribed(event DeployEvent, defaults []DeployEvent) *NotifyConfig {
+	var notifiers []*NotifierConfig
+	for _, n := range c.AllNotifiers() {
+		events := n.Events
+		if len(events) == 0 {
+			events = c.Events
+		}
+		if len(events) == 0 {
+			events = defaults
+		}
+		if slices.Contains(events, event) {
+			notifiers = append(notifiers, n)
+		}
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	subscribed := *c
+	subscribed.WebhookURL = ""
+	subscribed.Notifiers = notifiers
+	return &subscribed
+}
+
+// notifies 判断是否有通知渠道或 Webhook 订阅了任一事件
+func (c *NotifyConfig) notifies(defaults []DeployEvent, events ...DeployEvent) bool {
+	return slices.ContainsFunc(events, func(event DeployEvent) bool {
+		return c.subscribed(event, defaults) != nil || len(c.subscribedWebhooks(event)) > 0
+	})
+}
+
+// notify 向订阅了事件的通知渠道发送通知
+func (uc *DeployUsecase) notify(ctx context.Context, n *Notification) error {
+	config := n.Config.Notify
+	if config == nil || !config.Enabled {
+		return nil
+	}
+
+	subscribed := config.subscribed(n.Event, n.defaults)
+	webhooks := config.subscribedWebhooks(n.Event)
+	if subscribed == nil && len(webhooks) == 0 {
+		uc.log.WithContext(ctx).Infof("没有通知渠道订阅 %s 事件", n.Event)
+		return nil
+	}
+
+	var errs []error
+	if subscribed != nil {
+		if err := uc.repo.SendNotification(ctx, subscribed, uc.renderMessage(config, n)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(webhooks) > 0 {
+		if err := uc.sendWebhooks(ctx, config, webhooks, uc.webhookEvent(n)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// changedResources 对比集群差异，返回本次部署将变更的资源，用于通知；对比失败时返回空
+func (uc *DeployUsecase) changedResources(ctx context.Context, config *DeployConfig) []*ResourceDiff {
+	diffs, err := uc.Diff(ctx, config)
+	if err != nil {
+		uc.warn(ctx, "对比变更资源失败，通知中不包含变更资源: %v", err)
+		return nil
+	}
+
+	var changed []*ResourceDiff
+	for _, d := range diffs {
+		if d.Action != DiffUnchanged {
+			changed = append(changed, d)
+		}
+	}
+	return changed
+}
+
+// flushOutbox 补发上次未送达的通知，失败仅记录日志
+func (uc *DeployUsecase) flushOutbox(ctx context.Context, config *DeployConfig) {
+	if config.Notify == nil || !config.Notify.Enabled || config.Notify.OutboxDir == "" {
+		return
+	}
+
+	sent, err := uc.repo.FlushNotificationOutbox(ctx, config.Notify)
+	if err != nil {
+		uc.warn(ctx, "补发通知失败: %v", err)
+	}
+	if sent > 0 {
+		uc.log.WithContext(ctx).Infof("已补发 %d 条通知", sent)
+	}
+}
+
+// notifyEvent 发送生命周期通知，发送失败仅记录日志，不影响部署结果
+func (uc *DeployUsecase) notifyEvent(ctx context.Context, n *Notification) {
+	// 部署被取消时仍需发出失败通知
+	if err := uc.notify(context.WithoutCancel(ctx), n); err != nil {
+		uc.warn(ctx, "%s 通知发送失败: %v", n.Event, err)
+	}
+}
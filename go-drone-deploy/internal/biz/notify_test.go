@@ -0,0 +1,115 @@
+package biz
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestDeployFlushOutboxOnce 每次部署只补发一次发件箱，包括执行 notify 步骤的静默流水线
+func TestDeployFlushOutboxOnce(t *testing.T) {
+	tests := []struct {
+		name string
+		flow DeployFlow
+		want int
+	}{
+		{name: "发送生命周期通知", flow: "notified", want: 1},
+		{name: "notify 步骤", flow: FlowNotify, want: 1},
+		{name: "静默流水线", flow: "quiet", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			uc := newTestUsecase(repo)
+			config := &DeployConfig{
+				ProjectName: "demo",
+				Docker:      &DockerConfig{ImageName: "demo:v1"},
+				Notify:      &NotifyConfig{Enabled: true, OutboxDir: t.TempDir()},
+				Pipelines: map[DeployFlow]*Pipeline{
+					"notified": {Steps: []*PipelineStep{{Name: "notify"}}},
+					"quiet":    {Steps: []*PipelineStep{{Name: "build"}}, Quiet: true},
+				},
+			}
+
+			if _, err := uc.Deploy(context.Background(), config, tt.flow); err != nil {
+				t.Fatalf("Deploy() error = %v", err)
+			}
+			flushes := 0
+			for _, c := range repo.calls {
+				if c == "flush" {
+					flushes++
+				}
+			}
+			if flushes != tt.want {
+				t.Errorf("补发次数 = %d, want %d，调用: %v", flushes, tt.want, repo.calls)
+			}
+		})
+	}
+}
+
+// TestDeployLifecycleOptIn 未订阅事件时内置流程保持原有通知：all 只在成功后通知，k8s 不通知；
+// 配置订阅事件后按订阅发送生命周期通知，Webhook 始终接收全部事件
+func TestDeployLifecycleOptIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		flow   DeployFlow
+		notify *NotifyConfig
+		want   []string
+	}{
+		{name: "all 默认", flow: FlowAll, want: []string{"notify:succeeded"}},
+		{name: "k8s 默认", flow: FlowK8s},
+		{name: "自定义流水线默认", flow: "release"},
+		{
+			name:   "订阅生命周期事件",
+			flow:   FlowK8s,
+			notify: &NotifyConfig{WebhookURL: "https://slack", Events: []DeployEvent{EventStarted, EventFailed, EventSucceeded}},
+			want:   []string{"notify:started", "notify:succeeded"},
+		},
+		{
+			name:   "渠道单独订阅",
+			flow:   FlowAll,
+			notify: &NotifyConfig{WebhookURL: "https://slack", Notifiers: []*NotifierConfig{{Type: NotifierWeCom, WebhookURL: "https://wecom", Events: []DeployEvent{EventStarted}}}},
+			want:   []string{"notify:started", "notify:succeeded"},
+		},
+		{
+			name:   "Webhook",
+			flow:   FlowK8s,
+			notify: &NotifyConfig{Webhooks: []*WebhookConfig{{URL: "https://dashboard"}}},
+			want:   []string{"webhook:started", "webhook:succeeded"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			uc := newTestUsecase(repo)
+			notify := &NotifyConfig{WebhookURL: "https://slack"}
+			if tt.notify != nil {
+				notify = tt.notify
+			}
+			notify.Enabled = true
+			config := &DeployConfig{
+				ProjectName: "demo",
+				Docker:      &DockerConfig{ImageName: "demo:v1"},
+				K8s:         &K8sConfig{Namespace: "default", DeploymentName: "demo"},
+				Notify:      notify,
+				Pipelines: map[DeployFlow]*Pipeline{
+					"release": {Steps: []*PipelineStep{{Name: "deploy"}}},
+				},
+			}
+
+			if _, err := uc.Deploy(context.Background(), config, tt.flow); err != nil {
+				t.Fatalf("Deploy() error = %v", err)
+			}
+			var got []string
+			for _, c := range repo.calls {
+				if strings.HasPrefix(c, "notify:") || strings.HasPrefix(c, "webhook:") {
+					got = append(got, c)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("通知 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
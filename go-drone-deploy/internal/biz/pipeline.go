@@ -0,0 +1,287 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Pipeline 部署流水线，由按顺序或按依赖关系执行的步骤组成
+type Pipeline struct {
+	Steps []*PipelineStep
+	// Quiet 不发送开始、成功、失败、回滚等生命周期通知
+	Quiet bool
+
+	// events 未配置订阅事件的通知渠道默认接收的生命周期事件，生命周期通知需通过 NotifyConfig.Events 显式订阅
+	events []DeployEvent
+}
+
+// PipelineStep 流水线步骤
+type PipelineStep struct {
+	// Name 步骤名称，在流水线内唯一
+	Name string
+	// Uses 步骤类型，即注册的步骤名称，默认与 Name 相同
+	Uses string
+	// Needs 依赖的步骤。流水线中任一步骤设置了 Needs 时按依赖关系执行，
+	// 没有依赖的步骤立即开始；否则按列表顺序执行
+	Needs []string
+	// When 执行条件，不满足时跳过，不影响依赖它的步骤
+	When *StepCondition
+	// ContinueOnError 步骤失败时记录警告并继续，不导致部署失败
+	ContinueOnError bool
+	// Timeout 单次执行的超时时间，Retry 重试策略，未设置时使用 DeployConfig.StepPolicies
+	Timeout time.Duration
+	Retry   *StepRetry
+	// With 步骤参数
+	With map[string]string
+}
+
+// StepCondition 步骤执行条件，各项均支持通配符（如 release/*），设置的条件全部满足时执行
+type StepCondition struct {
+	Env    []string
+	Branch []string
+}
+
+// StepFunc 流水线步骤的实现；并行步骤共享同一份配置，实现不得修改 config
+type StepFunc func(ctx context.Context, config *DeployConfig, step *PipelineStep) error
+
+// uses 返回步骤类型
+func (s *PipelineStep) uses() string {
+	if s.Uses != "" {
+		return s.Uses
+	}
+	return s.Name
+}
+
+// uses 判断流水线是否包含指定类型的步骤
+func (p *Pipeline) uses(types ...string) bool {
+	return slices.ContainsFunc(p.Steps, func(s *PipelineStep) bool {
+		return slices.Contains(types, s.uses())
+	})
+}
+
+// dependencies 返回每个步骤依赖的步骤下标；未设置 Needs 的流水线依次依赖前一个步骤
+func (p *Pipeline) dependencies() [][]int {
+	index := make(map[string]int, len(p.Steps))
+	for i, s := range p.Steps {
+		index[s.Name] = i
+	}
+
+	dag := slices.ContainsFunc(p.Steps, func(s *PipelineStep) bool { return len(s.Needs) > 0 })
+	deps := make([][]int, len(p.Steps))
+	for i, s := range p.Steps {
+		switch {
+		case dag:
+			for _, need := range s.Needs {
+				deps[i] = append(deps[i], index[need])
+			}
+		case i > 0:
+			deps[i] = []int{i - 1}
+		}
+	}
+	return deps
+}
+
+// matches 判断条件是否满足
+func (c *StepCondition) matches(config *DeployConfig) bool {
+	if c == nil {
+		return true
+	}
+	return matchAny(c.Env, config.Env) && matchAny(c.Branch, config.Branch)
+}
+
+// matchAny 判断取值是否匹配任一模式，未设置模式时视为匹配
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, value); ok || (err != nil && pattern == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterStep 注册流水线步骤，重复注册时覆盖，应在部署前调用
+func (uc *DeployUsecase) RegisterStep(name string, fn StepFunc) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.steps[name] = fn
+}
+
+// stepFunc 返回注册的步骤实现
+func (uc *DeployUsecase) stepFunc(name string) (StepFunc, bool) {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	fn, ok := uc.steps[name]
+	return fn, ok
+}
+
+// pipeline 返回流程对应的流水线，配置中的流水线优先于内置流程
+func (uc *DeployUsecase) pipeline(config *DeployConfig, flow DeployFlow) (*Pipeline, error) {
+	pipeline, ok := config.Pipelines[flow]
+	if !ok {
+		pipeline, ok = presetPipelines[flow]
+	}
+	if !ok || pipeline == nil {
+		return nil, fmt.Errorf("不支持的部署流程: %s", flow)
+	}
+	if err := uc.validatePipeline(pipeline); err != nil {
+		return nil, fmt.Errorf("流水线 %s 配置错误: %w", flow, err)
+	}
+	return pipeline, nil
+}
+
+// validatePipeline 校验步骤名称、类型及依赖关系，依赖不能形成环
+func (uc *DeployUsecase) validatePipeline(p *Pipeline) error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("没有步骤")
+	}
+
+	names := map[string]bool{}
+	for _, s := range p.Steps {
+		if s.Name == "" {
+			return fmt.Errorf("步骤名称为空")
+		}
+		if names[s.Name] {
+			return fmt.Errorf("步骤 %s 重复", s.Name)
+		}
+		names[s.Name] = true
+		if _, ok := uc.stepFunc(s.uses()); !ok {
+			return fmt.Errorf("步骤 %s 的类型 %s 未注册", s.Name, s.uses())
+		}
+	}
+	for _, s := range p.Steps {
+		for _, need := range s.Needs {
+			if !names[need] {
+				return fmt.Errorf("步骤 %s 依赖的步骤 %s 不存在", s.Name, need)
+			}
+		}
+	}
+
+	// 深度优先检查依赖环
+	deps := p.dependencies()
+	state := make([]int, len(p.Steps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("步骤 %s 存在循环依赖", p.Steps[i].Name)
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, d := range deps[i] {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[i] = 2
+		return nil
+	}
+	for i := range p.Steps {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPipeline 执行流水线：步骤在依赖全部完成后开始，互不依赖的步骤并行执行。
+// 步骤失败（未设置 continue_on_error）后，尚未开始的步骤全部跳过，已开始的步骤执行完毕
+func (uc *DeployUsecase) runPipeline(ctx context.Context, config *DeployConfig, p *Pipeline) error {
+	// 调度步骤前确定部署元数据，此后配置由所有步骤只读共享
+	startedAt := time.Now()
+	if run := runFrom(ctx); run != nil {
+		startedAt = run.startedAt
+	}
+	config = uc.resolveConfig(config, startedAt)
+
+	deps := p.dependencies()
+	done := make([]chan struct{}, len(p.Steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var (
+		mu      sync.Mutex
+		aborted bool
+		errs    []error
+	)
+	runStep := func(i int) {
+		defer close(done[i])
+		step := p.Steps[i]
+		for _, d := range deps[i] {
+			<-done[d]
+		}
+
+		mu.Lock()
+		skip := aborted
+		mu.Unlock()
+		if skip || ctx.Err() != nil {
+			uc.log.WithContext(ctx).Infof("跳过步骤 %s: 前面的步骤失败", step.Name)
+			uc.skipStep(ctx, step.Name)
+			return
+		}
+		if !step.When.matches(config) {
+			uc.log.WithContext(ctx).Infof("跳过步骤 %s: 不满足执行条件", step.Name)
+			uc.skipStep(ctx, step.Name)
+			return
+		}
+
+		fn, _ := uc.stepFunc(step.uses())
+		uc.log.WithContext(ctx).Infof("开始步骤: %s", step.Name)
+		start := time.Now()
+		attempts, err := uc.execStep(ctx, config, step, fn)
+		if run := runFrom(ctx); run != nil {
+			finished := time.Now()
+			result := &StepResult{Name: step.Name, Status: StepSucceeded, StartedAt: start, FinishedAt: finished, Duration: finished.Sub(start), Attempts: attempts, Err: err}
+			if err != nil {
+				result.Status = StepFailed
+			}
+			run.record(result)
+		}
+		if err == nil {
+			return
+		}
+		if step.ContinueOnError {
+			uc.warn(ctx, "步骤 %s 失败，继续执行: %v", step.Name, err)
+			return
+		}
+
+		// 超时错误已包含步骤名称
+		var te *StepTimeoutError
+		if !errors.As(err, &te) {
+			err = fmt.Errorf("步骤 %s 失败: %w", step.Name, err)
+		}
+		mu.Lock()
+		aborted = true
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i := range p.Steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runStep(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// skipStep 记录跳过的步骤
+func (uc *DeployUsecase) skipStep(ctx context.Context, name string) {
+	if run := runFrom(ctx); run != nil {
+		now := time.Now()
+		run.record(&StepResult{Name: name, Status: StepSkipped, StartedAt: now, FinishedAt: now})
+	}
+}
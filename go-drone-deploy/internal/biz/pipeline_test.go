@@ -0,0 +1,110 @@
+package biz
+
+import (
+	"context"
+	"testing"
+)
+
+// TestRunPipelineParallelTargets 并行的步骤对每个目标集群执行时不修改共享配置，需配合 -race 运行
+func TestRunPipelineParallelTargets(t *testing.T) {
+	tests := []struct {
+		name       string
+		targets    []*Target
+		namespaces []string
+	}{
+		{name: "单集群", namespaces: []string{"default"}},
+		{name: "多集群", targets: []*Target{{Name: "a", Namespace: "ns-a"}, {Name: "b", Namespace: "ns-b"}}, namespaces: []string{"ns-a", "ns-b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testParallelSteps(t, tt.targets, tt.namespaces)
+		})
+	}
+}
+
+func testParallelSteps(t *testing.T, targets []*Target, namespaces []string) {
+	repo := newFakeRepo()
+	uc := newTestUsecase(repo)
+
+	config := &DeployConfig{
+		ProjectName: "demo",
+		Env:         "prod",
+		Version:     "v1",
+		Docker:      &DockerConfig{ImageName: "registry/demo:v1"},
+		K8s: &K8sConfig{
+			Namespace:        "default",
+			DeploymentName:   "demo",
+			ImagePullSecrets: []string{"base"},
+			Manifests:        &Manifests{Vars: map[string]string{"Custom": "x"}},
+			Bootstrap:        &NamespaceBootstrap{ImagePullSecret: "regcred"},
+			Hooks:            []*Hook{{Name: "migrate", Phase: HookPreDeploy}},
+			Targets:          targets,
+		},
+		Pipelines: map[DeployFlow]*Pipeline{
+			"parallel": {Steps: []*PipelineStep{
+				{Name: "push"},
+				{Name: "apply"},
+				{Name: "prune"},
+				{Name: "hooks"},
+				{Name: "rollout-wait", Needs: []string{"apply"}},
+				{Name: "sign", Needs: []string{"push"}},
+			}},
+		},
+	}
+
+	result, err := uc.Deploy(context.Background(), config, "parallel")
+	if err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	if result.ImageDigest != "registry/demo:v1@sha256:abc" {
+		t.Errorf("ImageDigest = %q", result.ImageDigest)
+	}
+	if !repo.called("sign:registry/demo:v1@sha256:abc") {
+		t.Errorf("sign 步骤未使用推送的摘要，调用: %v", repo.calls)
+	}
+	for _, ns := range namespaces {
+		for _, method := range []string{"deployment", "prune", "hook", "rollout"} {
+			if call := method + ":" + ns; !repo.called(call) {
+				t.Errorf("未调用 %s", call)
+			}
+		}
+	}
+	if len(result.Revisions) != len(namespaces) {
+		t.Errorf("Revisions = %d, want %d", len(result.Revisions), len(namespaces))
+	}
+
+	// 调用方的配置保持不变
+	if config.Docker.Digest != "" {
+		t.Errorf("Docker.Digest 被修改为 %q", config.Docker.Digest)
+	}
+	if config.K8s.Image != "" || config.K8s.DeployMeta != nil {
+		t.Errorf("K8s 配置被修改: image=%q meta=%v", config.K8s.Image, config.K8s.DeployMeta)
+	}
+	if len(config.K8s.ImagePullSecrets) != 1 || len(config.K8s.Manifests.Vars) != 1 {
+		t.Errorf("ImagePullSecrets = %v, Vars = %v", config.K8s.ImagePullSecrets, config.K8s.Manifests.Vars)
+	}
+}
+
+// TestPrepareK8sTargetVars 每个目标集群的内置模板变量使用该目标的命名空间
+func TestPrepareK8sTargetVars(t *testing.T) {
+	uc := newTestUsecase(newFakeRepo())
+	config := &DeployConfig{
+		K8s: &K8sConfig{
+			Namespace: "default",
+			Manifests: &Manifests{},
+			Targets:   []*Target{{Name: "a", Namespace: "ns-a"}, {Name: "b"}},
+		},
+	}
+
+	targets := uc.targetConfigs(config)
+	if got := targets[0].config.K8s.Manifests.Vars["Namespace"]; got != "ns-a" {
+		t.Errorf("目标 a Namespace = %q", got)
+	}
+	if got := targets[1].config.K8s.Manifests.Vars["Namespace"]; got != "default" {
+		t.Errorf("目标 b Namespace = %q", got)
+	}
+	if config.K8s.Manifests.Vars != nil {
+		t.Errorf("原配置的 Vars 被修改: %v", config.K8s.Manifests.Vars)
+	}
+}
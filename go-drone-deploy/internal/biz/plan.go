@@ -0,0 +1,215 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Plan 部署计划，描述按当前配置执行部署时将发生的操作
+type Plan struct {
+	Flow      DeployFlow
+	Project   string
+	Env       string
+	Version   string
+	Author    string
+	Branch    string
+	Commit    string
+	BuildLink string
+	// Image 部署使用的镜像引用
+	Image   string
+	Steps   []*PlanStep
+	Targets []*PlanTarget
+	// Objects 将应用的对象。Live 为 true 时通过服务端 dry-run 区分创建、更新、无变更及清理，
+	// 否则 Action 为 apply，且无法列出将被清理的对象
+	Objects []*ResourceDiff
+	Live    bool
+	// Prune 是否清理已从配置中移除的对象
+	Prune         bool
+	Notifications []*PlanNotification
+}
+
+// PlanStep 计划执行的步骤
+type PlanStep struct {
+	Name  string
+	Uses  string
+	Needs []string
+	// Skip 不满足执行条件，将被跳过
+	Skip            bool
+	ContinueOnError bool
+	Timeout         time.Duration
+	MaxAttempts     int
+}
+
+// PlanTarget 部署的目标集群
+type PlanTarget struct {
+	// Name 目标名称，单集群时为空
+	Name      string
+	Cluster   string
+	Namespace string
+}
+
+// PlanNotification 部署事件将发送到的通知渠道
+type PlanNotification struct {
+	Event DeployEvent
+	// Channel 渠道名称，Type 渠道类型，Webhook 的类型为 webhook
+	Channel string
+	Type    string
+}
+
+// Plan 生成部署计划。live 为 false 时不访问 Docker 和 Kubernetes，
+// 为 true 时以服务端 dry-run 对比集群差异，仍不做任何变更
+func (uc *DeployUsecase) Plan(ctx context.Context, config *DeployConfig, flow DeployFlow, live bool) (*Plan, error) {
+	pipeline, err := uc.pipeline(config, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Flow:      flow,
+		Project:   config.ProjectName,
+		Env:       config.Env,
+		Version:   config.Version,
+		Author:    config.Author,
+		Branch:    config.Branch,
+		Commit:    config.Commit,
+		BuildLink: config.BuildLink,
+		Live:      live,
+	}
+	if config.Docker != nil {
+		plan.Image = config.Docker.ImageName
+	}
+
+	for _, step := range pipeline.Steps {
+		timeout, retry := stepPolicy(config, step)
+		ps := &PlanStep{
+			Name:            step.Name,
+			Uses:            step.uses(),
+			Needs:           step.Needs,
+			Skip:            !step.When.matches(config),
+			ContinueOnError: step.ContinueOnError,
+			Timeout:         timeout,
+			MaxAttempts:     1,
+		}
+		if retry != nil {
+			ps.MaxAttempts = max(retry.MaxAttempts, 1)
+		}
+		plan.Steps = append(plan.Steps, ps)
+	}
+
+	if config.K8s != nil && pipeline.uses(StepDeploy, StepApply, StepHooks, StepRolloutWait, StepPrune, StepSetImage, StepRestart) {
+		if err := uc.planK8s(ctx, config, pipeline, plan); err != nil {
+			return nil, err
+		}
+	}
+
+	plan.Notifications = planNotifications(config, pipeline)
+	return plan, nil
+}
+
+// planK8s 填充目标集群、镜像及将应用的对象
+func (uc *DeployUsecase) planK8s(ctx context.Context, config *DeployConfig, pipeline *Pipeline, plan *Plan) error {
+	targets := uc.targetConfigs(config)
+	for _, tc := range targets {
+		plan.Targets = append(plan.Targets, &PlanTarget{
+			Name:      tc.name,
+			Cluster:   clusterOf(tc.config.K8s),
+			Namespace: tc.config.K8s.Namespace,
+		})
+	}
+	plan.Image = targets[0].config.K8s.Image
+
+	switch {
+	case pipeline.uses(StepDeploy, StepApply):
+		plan.Prune = config.K8s.Prune && pipeline.uses(StepDeploy, StepPrune)
+		if plan.Live {
+			diffs, err := uc.Diff(ctx, config)
+			if err != nil {
+				return err
+			}
+			plan.Objects = diffs
+			return nil
+		}
+		for _, tc := range targets {
+			manifests, err := uc.repo.RenderK8s(ctx, tc.config.K8s)
+			if err != nil {
+				return fmt.Errorf("渲染 Kubernetes 资源失败%s: %w", tc.label(), err)
+			}
+			for _, m := range manifests {
+				plan.Objects = append(plan.Objects, &ResourceDiff{Target: tc.name, Kind: m.Kind, Namespace: m.Namespace, Name: m.Name, Action: DiffApply})
+			}
+		}
+	case pipeline.uses(StepSetImage, StepRestart):
+		// 仅修改已有的 Deployment
+		for _, tc := range targets {
+			plan.Objects = append(plan.Objects, &ResourceDiff{Target: tc.name, Kind: "Deployment", Namespace: tc.config.K8s.Namespace, Name: tc.config.K8s.DeploymentName, Action: DiffUpdate})
+		}
+	}
+	return nil
+}
+
+// planNotifications 列出每个部署事件将发送到的渠道
+func planNotifications(config *DeployConfig, pipeline *Pipeline) []*PlanNotification {
+	notify := config.Notify
+	if notify == nil || !notify.Enabled {
+		return nil
+	}
+
+	var events, defaults []DeployEvent
+	if !pipeline.Quiet {
+		events = []DeployEvent{EventStarted, EventSucceeded, EventFailed}
+		if config.K8s != nil && len(config.K8s.Targets) > 0 && config.K8s.FailurePolicy == FailureRollback && pipeline.uses(StepDeploy) {
+			events = append(events, EventRolledBack)
+		}
+		defaults = pipeline.events
+	} else if pipeline.uses(StepNotify) {
+		events = []DeployEvent{EventSucceeded}
+		defaults = events
+	}
+
+	var result []*PlanNotification
+	for _, event := range events {
+		if subscribed := notify.subscribed(event, defaults); subscribed != nil {
+			for _, n := range subscribed.Notifiers {
+				notifierType := n.Type
+				if notifierType == "" {
+					notifierType = NotifierSlack
+				}
+				name := n.Name
+				if name == "" {
+					name = string(notifierType)
+				}
+				result = append(result, &PlanNotification{Event: event, Channel: name, Type: string(notifierType)})
+			}
+		}
+		for _, w := range notify.subscribedWebhooks(event) {
+			name := w.Name
+			if u, err := url.Parse(w.URL); name == "" && err == nil {
+				name = u.Host
+			}
+			result = append(result, &PlanNotification{Event: event, Channel: name, Type: "webhook"})
+		}
+	}
+	return result
+}
+
+// clusterOf 描述 Kubernetes 连接方式
+func clusterOf(config *K8sConfig) string {
+	switch {
+	case config.Server != "":
+		return config.Server
+	case config.Kubeconfig != "" && config.Context != "":
+		return "内联 kubeconfig，context " + config.Context
+	case config.Kubeconfig != "":
+		return "内联 kubeconfig"
+	case config.Context != "":
+		return "context " + config.Context
+	case config.KubeconfigPath != "":
+		return config.KubeconfigPath
+	case config.InCluster:
+		return "集群内 ServiceAccount"
+	default:
+		return "默认 kubeconfig"
+	}
+}
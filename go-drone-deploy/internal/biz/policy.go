@@ -0,0 +1,157 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// ErrorClass 错误类别，用于判断步骤失败后是否重试
+type ErrorClass string
+
+const (
+	ErrorTimeout   ErrorClass = "timeout"   // 步骤超时或请求超时
+	ErrorNetwork   ErrorClass = "network"   // 连接失败、连接被重置等网络错误
+	ErrorThrottled ErrorClass = "throttled" // 被限流（HTTP 429）
+	ErrorServer    ErrorClass = "server"    // 服务端错误（HTTP 5xx、API Server 不可用）
+	ErrorConflict  ErrorClass = "conflict"  // 资源冲突（HTTP 409）
+	ErrorExec      ErrorClass = "exec"      // 外部命令（docker 等）以非零状态退出
+	ErrorAny       ErrorClass = "any"       // 任意错误
+)
+
+const (
+	// defaultStepBackoff 步骤首次重试前的默认等待时间
+	defaultStepBackoff = 5 * time.Second
+	// defaultStepMaxBackoff 步骤重试单次等待的默认上限
+	defaultStepMaxBackoff = time.Minute
+)
+
+// defaultRetryOn 默认重试的错误类别
+var defaultRetryOn = []ErrorClass{ErrorTimeout, ErrorNetwork, ErrorThrottled, ErrorServer}
+
+// retryOn 返回步骤默认重试的错误类别；构建、推送镜像时 docker 的网络错误只体现为非零退出状态，另外重试 exec
+func retryOn(step *PipelineStep) []ErrorClass {
+	switch step.uses() {
+	case StepBuild, StepPush:
+		return append(slices.Clone(defaultRetryOn), ErrorExec)
+	}
+	return defaultRetryOn
+}
+
+// StepPolicy 步骤的超时与重试策略
+type StepPolicy struct {
+	// Timeout 单次执行的超时时间，为零时不限制
+	Timeout time.Duration
+	Retry   *StepRetry
+}
+
+// StepRetry 步骤重试策略，等待时间按指数增长
+type StepRetry struct {
+	// MaxAttempts 最多执行次数，为零或一时不重试
+	MaxAttempts int
+	// InitialBackoff 首次重试前的等待时间，默认 5 秒
+	InitialBackoff time.Duration
+	// MaxBackoff 单次等待的上限，默认 1 分钟
+	MaxBackoff time.Duration
+	// On 重试的错误类别，默认 timeout、network、throttled、server，build、push 步骤另有 exec
+	On []ErrorClass
+}
+
+// StepTimeoutError 步骤执行超时
+type StepTimeoutError struct {
+	Step    string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("步骤 %s 执行 %s 后超时: %v", e.Step, e.Timeout, e.Err)
+}
+
+func (e *StepTimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.Err}
+}
+
+// stepPolicy 返回步骤的超时与重试策略：步骤自身的设置优先，其次为按步骤名称、步骤类型配置的策略
+func stepPolicy(config *DeployConfig, step *PipelineStep) (time.Duration, *StepRetry) {
+	timeout, retry := step.Timeout, step.Retry
+	for _, key := range []string{step.Name, step.uses()} {
+		if p := config.StepPolicies[key]; p != nil {
+			if timeout == 0 {
+				timeout = p.Timeout
+			}
+			if retry == nil {
+				retry = p.Retry
+			}
+		}
+	}
+	return timeout, retry
+}
+
+// execStep 按策略执行步骤，返回执行次数；每次执行使用独立的超时上下文，可重试的错误按指数退避重试
+func (uc *DeployUsecase) execStep(ctx context.Context, config *DeployConfig, step *PipelineStep, fn StepFunc) (int, error) {
+	timeout, retry := stepPolicy(config, step)
+	attempts, backoff, maxBackoff, on := 1, defaultStepBackoff, defaultStepMaxBackoff, retryOn(step)
+	if retry != nil {
+		attempts = max(retry.MaxAttempts, 1)
+		if retry.InitialBackoff > 0 {
+			backoff = retry.InitialBackoff
+		}
+		if retry.MaxBackoff > 0 {
+			maxBackoff = retry.MaxBackoff
+		}
+		if len(retry.On) > 0 {
+			on = retry.On
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := uc.attemptStep(ctx, config, step, fn, timeout)
+		if err == nil || attempt >= attempts || ctx.Err() != nil {
+			return attempt, err
+		}
+		class := uc.errorClass(err)
+		if !slices.Contains(on, ErrorAny) && (class == "" || !slices.Contains(on, class)) {
+			return attempt, err
+		}
+
+		// 指数退避加 ±20% 抖动
+		wait := time.Duration(float64(backoff) * (0.8 + 0.4*rand.Float64()))
+		uc.log.WithContext(ctx).Warnf("步骤 %s 第 %d 次执行失败（%s），%s 后重试: %v", step.Name, attempt, class, wait.Round(time.Millisecond), err)
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// attemptStep 执行一次步骤，超时后返回 StepTimeoutError
+func (uc *DeployUsecase) attemptStep(ctx context.Context, config *DeployConfig, step *PipelineStep, fn StepFunc, timeout time.Duration) error {
+	if timeout <= 0 {
+		return fn(ctx, config, step)
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := fn(stepCtx, config, step)
+	// 仅步骤自身的超时转换为超时错误，外部取消保持原样
+	if err != nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return &StepTimeoutError{Step: step.Name, Timeout: timeout, Err: err}
+	}
+	return err
+}
+
+// errorClass 返回错误类别，无法识别时为空
+func (uc *DeployUsecase) errorClass(err error) ErrorClass {
+	var te *StepTimeoutError
+	if errors.As(err, &te) {
+		return ErrorTimeout
+	}
+	return uc.repo.ClassifyError(err)
+}
@@ -0,0 +1,85 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecStep(t *testing.T) {
+	var (
+		errNetwork = errors.New("连接被重置")
+		errExec    = errors.New("docker 退出状态 1")
+		errInvalid = errors.New("清单无效")
+	)
+	classes := map[error]ErrorClass{errNetwork: ErrorNetwork, errExec: ErrorExec}
+
+	retry := &StepRetry{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	tests := []struct {
+		name string
+		step *PipelineStep
+		// errs 每次执行返回的错误，用完后成功
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{"成功", &PipelineStep{Name: "deploy", Retry: retry}, nil, 1, nil},
+		{"网络错误重试后成功", &PipelineStep{Name: "deploy", Retry: retry}, []error{errNetwork, errNetwork}, 3, nil},
+		{"超过次数", &PipelineStep{Name: "deploy", Retry: retry}, []error{errNetwork, errNetwork, errNetwork}, 3, errNetwork},
+		{"不可重试", &PipelineStep{Name: "deploy", Retry: retry}, []error{errInvalid}, 1, errInvalid},
+		{"未配置重试", &PipelineStep{Name: "deploy"}, []error{errNetwork}, 1, errNetwork},
+		{"push 默认重试命令失败", &PipelineStep{Name: "push", Retry: retry}, []error{errExec}, 2, nil},
+		{"build 默认重试命令失败", &PipelineStep{Name: "image", Uses: StepBuild, Retry: retry}, []error{errExec}, 2, nil},
+		{"其他步骤默认不重试命令失败", &PipelineStep{Name: "scan", Retry: retry}, []error{errExec}, 1, errExec},
+		{"指定类别", &PipelineStep{Name: "deploy", Retry: &StepRetry{MaxAttempts: 3, InitialBackoff: time.Millisecond, On: []ErrorClass{ErrorExec}}},
+			[]error{errNetwork}, 1, errNetwork},
+		{"任意错误", &PipelineStep{Name: "deploy", Retry: &StepRetry{MaxAttempts: 2, InitialBackoff: time.Millisecond, On: []ErrorClass{ErrorAny}}},
+			[]error{errInvalid}, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.classify = func(err error) ErrorClass { return classes[err] }
+			uc := newTestUsecase(repo)
+
+			calls := 0
+			fn := func(ctx context.Context, config *DeployConfig, step *PipelineStep) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			}
+
+			attempts, err := uc.execStep(context.Background(), &DeployConfig{}, tt.step, fn)
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("attempts = %d, calls = %d, want %d", attempts, calls, tt.wantAttempts)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestExecStepTimeout 单次执行超时的错误按 timeout 类别重试
+func TestExecStepTimeout(t *testing.T) {
+	uc := newTestUsecase(newFakeRepo())
+	step := &PipelineStep{Name: "deploy", Timeout: 10 * time.Millisecond, Retry: &StepRetry{MaxAttempts: 2, InitialBackoff: time.Millisecond}}
+
+	calls := 0
+	attempts, err := uc.execStep(context.Background(), &DeployConfig{}, step, func(ctx context.Context, config *DeployConfig, step *PipelineStep) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if attempts != 2 || calls != 2 {
+		t.Errorf("attempts = %d, calls = %d, want 2", attempts, calls)
+	}
+	var te *StepTimeoutError
+	if !errors.As(err, &te) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want StepTimeoutError", err)
+	}
+}
@@ -0,0 +1,49 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// TestRender 渲染不写入部署时间，并渲染每个目标集群
+func TestRender(t *testing.T) {
+	uc := newTestUsecase(newFakeRepo())
+	config := &DeployConfig{
+		ProjectName: "demo",
+		K8s: &K8sConfig{
+			Namespace:      "default",
+			DeploymentName: "demo",
+			Targets: []*Target{
+				{Name: "a", Namespace: "ns-a"},
+				{Name: "b", Namespace: "ns-b"},
+			},
+		},
+	}
+
+	first, err := uc.Render(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	second, err := uc.Render(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if len(first) != 2 {
+		t.Fatalf("len(manifests) = %d, want 2", len(first))
+	}
+	for i, want := range []struct{ target, namespace string }{{"a", "ns-a"}, {"b", "ns-b"}} {
+		if m := first[i]; m.Target != want.target || m.Namespace != want.namespace {
+			t.Errorf("manifests[%d] = %s/%s, want %s/%s", i, m.Target, m.Namespace, want.target, want.namespace)
+		}
+		if string(first[i].Content) != (time.Time{}).String() {
+			t.Errorf("manifests[%d] 写入了部署时间: %s", i, first[i].Content)
+		}
+		if !bytes.Equal(first[i].Content, second[i].Content) {
+			t.Errorf("manifests[%d] 两次渲染的结果不同", i)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// fakeRepo 记录调用的内存部署仓库
+type fakeRepo struct {
+	mu    sync.Mutex
+	calls []string
+	// fail 按 "方法" 或 "方法:命名空间" 返回的错误
+	fail map[string]error
+	// revisions 按命名空间记录的 Deployment 版本号，ApplyK8sDeployment 时递增
+	revisions map[string]int64
+	// unchanged 应用后 Pod 模板不变、版本号不递增的命名空间
+	unchanged map[string]bool
+	// classify ClassifyError 的结果
+	classify func(err error) ErrorClass
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{fail: map[string]error{}, revisions: map[string]int64{}, unchanged: map[string]bool{}}
+}
+
+func newTestUsecase(repo DeployRepo) *DeployUsecase {
+	return NewDeployUsecase(repo, log.NewStdLogger(io.Discard))
+}
+
+// k8s 记录调用并读取配置的全部可变字段，配置被并发修改时可由 -race 发现
+func (r *fakeRepo) k8s(method string, config *K8sConfig) error {
+	var b strings.Builder
+	fmt.Fprint(&b, config.Image, config.Version, config.DeployMeta, config.ImagePullSecrets)
+	if config.Manifests != nil {
+		for k, v := range config.Manifests.Vars {
+			b.WriteString(k + v)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, method+":"+config.Namespace)
+	if err := r.fail[method+":"+config.Namespace]; err != nil {
+		return err
+	}
+	return r.fail[method]
+}
+
+func (r *fakeRepo) called(call string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeRepo) docker(method string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, method)
+	return r.fail[method]
+}
+
+func (r *fakeRepo) BuildDockerImage(ctx context.Context, config *DockerConfig) error {
+	return r.docker("build")
+}
+
+func (r *fakeRepo) ScanDockerImage(ctx context.Context, config *DockerConfig) error {
+	return r.docker("scan")
+}
+
+func (r *fakeRepo) SignDockerImage(ctx context.Context, config *DockerConfig) error {
+	return r.docker("sign:" + config.Digest)
+}
+
+func (r *fakeRepo) PushDockerImage(ctx context.Context, config *DockerConfig) (string, error) {
+	if err := r.docker("push"); err != nil {
+		return "", err
+	}
+	return config.ImageName + "@sha256:abc", nil
+}
+
+func (r *fakeRepo) ApplyK8sDeployment(ctx context.Context, config *K8sConfig) error {
+	if err := r.k8s("deployment", config); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.unchanged[config.Namespace] {
+		r.revisions[config.Namespace]++
+	}
+	return nil
+}
+
+func (r *fakeRepo) ApplyK8sService(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("service", config)
+}
+
+func (r *fakeRepo) SetK8sImage(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("set-image", config)
+}
+
+func (r *fakeRepo) RestartK8sDeployment(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("restart", config)
+}
+
+func (r *fakeRepo) ApplyK8sAutoscaler(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("hpa", config)
+}
+
+func (r *fakeRepo) ApplyK8sPodDisruptionBudget(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("pdb", config)
+}
+
+func (r *fakeRepo) DiffK8s(ctx context.Context, config *K8sConfig, docker *DockerConfig) ([]*ResourceDiff, error) {
+	return nil, r.k8s("diff", config)
+}
+
+// RenderK8s 返回以部署时间为内容的 Deployment 清单
+func (r *fakeRepo) RenderK8s(ctx context.Context, config *K8sConfig) ([]*Manifest, error) {
+	if err := r.k8s("render", config); err != nil {
+		return nil, err
+	}
+	return []*Manifest{{
+		Kind:      "Deployment",
+		Namespace: config.Namespace,
+		Name:      config.DeploymentName,
+		Content:   []byte(config.DeployMeta.Time.String()),
+	}}, nil
+}
+
+func (r *fakeRepo) ApplyK8sManifests(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("manifests", config)
+}
+
+func (r *fakeRepo) PruneK8s(ctx context.Context, config *K8sConfig, dryRun bool) ([]*ResourceRef, error) {
+	return nil, r.k8s("prune", config)
+}
+
+func (r *fakeRepo) RunK8sHook(ctx context.Context, config *K8sConfig, hook *Hook) error {
+	return r.k8s("hook", config)
+}
+
+func (r *fakeRepo) WaitK8sRollout(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("rollout", config)
+}
+
+func (r *fakeRepo) CollectK8sDiagnostics(ctx context.Context, config *K8sConfig) (*Diagnostics, error) {
+	return nil, fmt.Errorf("不支持")
+}
+
+func (r *fakeRepo) BootstrapK8sNamespace(ctx context.Context, config *K8sConfig, docker *DockerConfig) error {
+	return r.k8s("bootstrap", config)
+}
+
+func (r *fakeRepo) RollbackK8sDeployment(ctx context.Context, config *K8sConfig) error {
+	return r.k8s("rollback", config)
+}
+
+func (r *fakeRepo) GetK8sRevision(ctx context.Context, config *K8sConfig) (int64, error) {
+	if err := r.k8s("revision", config); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revisions[config.Namespace], nil
+}
+
+func (r *fakeRepo) ClassifyError(err error) ErrorClass {
+	if r.classify != nil {
+		return r.classify(err)
+	}
+	return ""
+}
+
+func (r *fakeRepo) SendNotification(ctx context.Context, config *NotifyConfig, message *Message) error {
+	return r.docker("notify:" + string(message.Event))
+}
+
+func (r *fakeRepo) FlushNotificationOutbox(ctx context.Context, config *NotifyConfig) (int, error) {
+	return 0, r.docker("flush")
+}
+
+func (r *fakeRepo) SendWebhookEvent(ctx context.Context, config *NotifyConfig, webhooks []*WebhookConfig, event *WebhookEvent) error {
+	return r.docker("webhook:" + string(event.Type))
+}
+
+func (r *fakeRepo) SaveWebhookEvent(ctx context.Context, config *NotifyConfig, event *WebhookEvent) error {
+	return nil
+}
+
+func (r *fakeRepo) LoadWebhookEvents(ctx context.Context, config *NotifyConfig, id string) ([]*WebhookEvent, error) {
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// DeployStatus 部署结果状态
+type DeployStatus string
+
+const (
+	DeploySucceeded  DeployStatus = "succeeded"   // 部署成功
+	DeployFailed     DeployStatus = "failed"      // 部署失败
+	DeployRolledBack DeployStatus = "rolled_back" // 部署失败，已回滚部分或全部目标集群
+)
+
+// DeployResult 部署结果
+type DeployResult struct {
+	DeployID   string
+	Flow       DeployFlow
+	Status     DeployStatus
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   time.Duration
+	// Steps 流水线各步骤的结果，按完成顺序排列
+	Steps       []*StepResult
+	Image       string
+	ImageDigest string
+	// Revisions 部署后每个目标集群 Deployment 的版本号
+	Revisions []*Revision
+	// Targets 多集群部署时每个目标的结果
+	Targets []*TargetResult
+	// Warnings 未导致部署失败的问题，如通知发送失败、允许失败的步骤失败
+	Warnings []string
+	Err      error
+}
+
+// Revision Deployment 版本号
+type Revision struct {
+	// Target 目标集群名称，单集群时为空
+	Target   string
+	Revision int64
+}
+
+// revisionSteps 会产生新 Deployment 版本的步骤
+var revisionSteps = []string{StepDeploy, StepApply, StepSetImage, StepRestart}
+
+// result 生成部署结果，执行过 Kubernetes 步骤时读取各目标集群的 Deployment 版本号
+func (uc *DeployUsecase) result(ctx context.Context, config *DeployConfig, pipeline *Pipeline, run *deployRun, err error) *DeployResult {
+	result := &DeployResult{
+		DeployID:  run.id,
+		Flow:      run.flow,
+		Status:    DeploySucceeded,
+		StartedAt: run.startedAt,
+		Steps:     run.Steps(),
+		Targets:   run.Targets(),
+		Err:       err,
+	}
+	switch {
+	case len(rolledBackTargets(err)) > 0:
+		result.Status = DeployRolledBack
+	case err != nil:
+		result.Status = DeployFailed
+	}
+	if config.Docker != nil {
+		result.Image = config.Docker.ImageName
+		result.ImageDigest = config.Docker.Digest
+	}
+	if digest := run.Digest(); digest != "" {
+		result.ImageDigest = digest
+	}
+	if config.K8s != nil && config.K8s.Image != "" {
+		result.Image = config.K8s.Image
+	}
+
+	if config.K8s != nil && ran(pipeline, result.Steps, revisionSteps) && !errors.Is(ctx.Err(), context.Canceled) {
+		for _, tc := range uc.targetConfigs(config) {
+			revision, revErr := uc.repo.GetK8sRevision(ctx, tc.config.K8s)
+			if revErr != nil {
+				uc.warn(ctx, "获取 Deployment 版本号失败%s: %v", tc.label(), revErr)
+				continue
+			}
+			result.Revisions = append(result.Revisions, &Revision{Target: tc.name, Revision: revision})
+		}
+	}
+
+	result.FinishedAt = time.Now()
+	result.Duration = result.FinishedAt.Sub(result.StartedAt)
+	result.Warnings = run.Warnings()
+	return result
+}
+
+// ran 判断流水线是否执行过指定类型的步骤
+func ran(pipeline *Pipeline, steps []*StepResult, types []string) bool {
+	for _, s := range pipeline.Steps {
+		if !slices.Contains(types, s.uses()) {
+			continue
+		}
+		if slices.ContainsFunc(steps, func(r *StepResult) bool { return r.Name == s.Name && r.Status != StepSkipped }) {
+			return true
+		}
+	}
+	return false
+}
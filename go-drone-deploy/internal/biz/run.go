@@ -0,0 +1,129 @@
+package biz
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// StepStatus 部署步骤的结果
+type StepStatus string
+
+const (
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	// StepSkipped 条件不满足或依赖的步骤失败，未执行
+	StepSkipped StepStatus = "skipped"
+)
+
+// StepResult 单个部署步骤的执行结果
+type StepResult struct {
+	Name       string
+	Status     StepStatus
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   time.Duration
+	// Attempts 执行次数，包括重试
+	Attempts int
+	Err      error
+}
+
+// deployRun 一次部署的运行状态，随上下文传递，记录每个步骤的结果
+type deployRun struct {
+	id        string
+	flow      DeployFlow
+	startedAt time.Time
+	// events 未订阅事件的通知渠道默认接收的事件
+	events []DeployEvent
+
+	mu       sync.Mutex
+	steps    []*StepResult
+	targets  []*TargetResult
+	warnings []string
+	// digest push 步骤推送的镜像摘要
+	digest string
+}
+
+type runKey struct{}
+
+// newID 生成随机标识
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withDeployRun 开始一次部署运行
+func withDeployRun(ctx context.Context, flow DeployFlow, events []DeployEvent) (context.Context, *deployRun) {
+	run := &deployRun{id: newID(), flow: flow, startedAt: time.Now(), events: events}
+	return context.WithValue(ctx, runKey{}, run), run
+}
+
+// runFrom 返回上下文中的部署运行，不在部署中时为 nil
+func runFrom(ctx context.Context) *deployRun {
+	run, _ := ctx.Value(runKey{}).(*deployRun)
+	return run
+}
+
+// Steps 返回已记录步骤的副本
+func (r *deployRun) Steps() []*StepResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.steps)
+}
+
+// Targets 返回多集群部署的目标结果
+func (r *deployRun) Targets() []*TargetResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.targets)
+}
+
+// setTargets 记录多集群部署的目标结果
+func (r *deployRun) setTargets(results []*TargetResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets = results
+}
+
+// Digest 返回本次部署推送的镜像摘要
+func (r *deployRun) Digest() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.digest
+}
+
+// setDigest 记录推送的镜像摘要
+func (r *deployRun) setDigest(digest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.digest = digest
+}
+
+// Warnings 返回已记录的警告
+func (r *deployRun) Warnings() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.warnings)
+}
+
+// warn 记录警告日志，部署中时同时加入部署结果
+func (uc *DeployUsecase) warn(ctx context.Context, format string, args ...interface{}) {
+	uc.log.WithContext(ctx).Warnf(format, args...)
+	if run := runFrom(ctx); run != nil {
+		run.mu.Lock()
+		run.warnings = append(run.warnings, fmt.Sprintf(format, args...))
+		run.mu.Unlock()
+	}
+}
+
+// record 记录步骤结果
+func (r *deployRun) record(result *StepResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, result)
+}
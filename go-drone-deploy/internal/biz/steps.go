@@ -0,0 +1,141 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+)
+
+// 内置步骤类型
+const (
+	StepBuild       = "build"        // 构建 Docker 镜像
+	StepScan        = "scan"         // 使用 trivy 扫描镜像漏洞
+	StepPush        = "push"         // 推送镜像并记录摘要
+	StepSign        = "sign"         // 使用 cosign 签名镜像
+	StepDeploy      = "deploy"       // 完整的 Kubernetes 部署，支持多集群、失败策略与诊断
+	StepApply       = "apply"        // 初始化命名空间、应用额外清单及 Deployment、Service、HPA、PDB
+	StepHooks       = "hooks"        // 运行钩子，参数 phase 为 pre-deploy（默认）或 post-deploy
+	StepRolloutWait = "rollout-wait" // 等待 Deployment 发布完成
+	StepPrune       = "prune"        // 清理已从配置中移除的资源
+	StepSetImage    = "set-image"    // 仅更新 Deployment 镜像并等待发布完成
+	StepRestart     = "restart"      // 滚动重启 Deployment 并等待发布完成
+	StepNotify      = "notify"       // 发送部署成功通知
+)
+
+// steps 按名称生成顺序执行的步骤
+func steps(names ...string) []*PipelineStep {
+	var result []*PipelineStep
+	for _, name := range names {
+		result = append(result, &PipelineStep{Name: name})
+	}
+	return result
+}
+
+// presetPipelines 内置流程
+var presetPipelines = map[DeployFlow]*Pipeline{
+	// all 流程默认只在部署成功后通知，与原有的通知阶段一致
+	FlowAll:      {Steps: steps(StepBuild, StepPush, StepDeploy), events: []DeployEvent{EventSucceeded}},
+	FlowDocker:   {Steps: steps(StepBuild, StepPush), Quiet: true},
+	FlowK8s:      {Steps: steps(StepDeploy)},
+	FlowNotify:   {Steps: steps(StepNotify), Quiet: true},
+	FlowStandard: {Steps: steps(StepBuild, StepPush, StepDeploy), Quiet: true},
+	FlowSetImage: {Steps: steps(StepSetImage)},
+	FlowRestart:  {Steps: steps(StepRestart)},
+}
+
+// registerBuiltinSteps 注册内置步骤
+func (uc *DeployUsecase) registerBuiltinSteps() {
+	uc.RegisterStep(StepBuild, uc.docker("构建", uc.repo.BuildDockerImage))
+	uc.RegisterStep(StepScan, uc.docker("扫描", uc.repo.ScanDockerImage))
+	uc.RegisterStep(StepPush, uc.docker("推送", func(ctx context.Context, config *DockerConfig) error {
+		digest, err := uc.repo.PushDockerImage(ctx, config)
+		if err != nil {
+			return err
+		}
+		// 摘要记录在部署运行中，不修改步骤间共享的配置
+		if run := runFrom(ctx); run != nil {
+			run.setDigest(digest)
+		}
+		return nil
+	}))
+	uc.RegisterStep(StepSign, uc.docker("签名", uc.repo.SignDockerImage))
+
+	uc.RegisterStep(StepDeploy, func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		return uc.deployK8s(ctx, config)
+	})
+	uc.RegisterStep(StepApply, uc.eachTarget(func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		if err := uc.applyDependencies(ctx, config); err != nil {
+			return err
+		}
+		return uc.applyResources(ctx, config)
+	}))
+	uc.RegisterStep(StepHooks, uc.eachTarget(func(ctx context.Context, config *DeployConfig, step *PipelineStep) error {
+		phase := HookPhase(step.With["phase"])
+		if phase == "" {
+			phase = HookPreDeploy
+		}
+		if phase != HookPreDeploy && phase != HookPostDeploy {
+			return fmt.Errorf("不支持的钩子阶段: %s", phase)
+		}
+		return uc.runHooks(ctx, config.K8s, phase)
+	}))
+	uc.RegisterStep(StepRolloutWait, uc.eachTarget(func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		if err := uc.repo.WaitK8sRollout(ctx, config.K8s); err != nil {
+			return fmt.Errorf("Kubernetes Deployment 发布失败: %w", err)
+		}
+		return nil
+	}))
+	uc.RegisterStep(StepPrune, uc.eachTarget(func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		if _, err := uc.repo.PruneK8s(ctx, config.K8s, false); err != nil {
+			return fmt.Errorf("清理 Kubernetes 资源失败: %w", err)
+		}
+		return nil
+	}))
+	uc.RegisterStep(StepSetImage, func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		return uc.rolloutK8s(ctx, config, "更新镜像", uc.repo.SetK8sImage)
+	})
+	uc.RegisterStep(StepRestart, func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		return uc.rolloutK8s(ctx, config, "重启", uc.repo.RestartK8sDeployment)
+	})
+
+	uc.RegisterStep(StepNotify, func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		return uc.deployNotify(ctx, config)
+	})
+}
+
+// docker 生成 Docker 镜像步骤
+func (uc *DeployUsecase) docker(action string, fn func(ctx context.Context, config *DockerConfig) error) StepFunc {
+	return func(ctx context.Context, config *DeployConfig, _ *PipelineStep) error {
+		if config.Docker == nil {
+			return fmt.Errorf("Docker 配置为空")
+		}
+		// 使用副本并补上已推送镜像的摘要
+		docker := *config.Docker
+		if run := runFrom(ctx); run != nil && run.Digest() != "" {
+			docker.Digest = run.Digest()
+		}
+		uc.log.WithContext(ctx).Infof("开始%s Docker 镜像", action)
+		if err := fn(ctx, &docker); err != nil {
+			return fmt.Errorf("%s Docker 镜像失败: %w", action, err)
+		}
+		return nil
+	}
+}
+
+// eachTarget 生成依次对每个目标集群执行的 Kubernetes 步骤，任一目标失败即返回；
+// 多集群的并行部署、失败策略和诊断只适用于 deploy 步骤
+func (uc *DeployUsecase) eachTarget(fn StepFunc) StepFunc {
+	return func(ctx context.Context, config *DeployConfig, step *PipelineStep) error {
+		if config.K8s == nil {
+			return fmt.Errorf("Kubernetes 配置为空")
+		}
+		for _, tc := range uc.targetConfigs(config) {
+			if err := fn(ctx, tc.config, step); err != nil {
+				if tc.name != "" {
+					return fmt.Errorf("目标 %s: %w", tc.name, err)
+				}
+				return err
+			}
+		}
+		return nil
+	}
+}
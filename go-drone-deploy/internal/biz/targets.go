@@ -0,0 +1,266 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailurePolicy 多集群部署的失败处理策略
+type FailurePolicy string
+
+const (
+	FailureStop     FailurePolicy = "stop"     // 任一目标失败后不再部署其余目标（默认）
+	FailureContinue FailurePolicy = "continue" // 继续部署其余目标
+	FailureRollback FailurePolicy = "rollback" // 任一目标失败时回滚所有已部署的目标
+)
+
+// Target 部署目标集群，未设置的字段沿用 K8sConfig 中的值；
+// 设置了 kubeconfig 或集群地址时使用独立的连接，不沿用 K8sConfig 中的连接与认证信息
+type Target struct {
+	Name                  string
+	KubeconfigPath        string
+	Kubeconfig            string
+	Context               string
+	Server                string
+	Token                 string
+	TokenFile             string
+	CAFile                string
+	CAData                string
+	InsecureSkipTLSVerify bool
+	Namespace             string
+}
+
+// TargetResult 单个目标集群的部署结果
+type TargetResult struct {
+	Target     string
+	Namespace  string
+	Err        error
+	Skipped    bool
+	RolledBack bool
+	Duration   time.Duration
+}
+
+// TargetsError 多集群部署失败错误，包含每个目标的结果
+type TargetsError struct {
+	Results []*TargetResult
+}
+
+func (e *TargetsError) Error() string {
+	var failed []string
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Target, r.Err))
+		}
+	}
+	return fmt.Sprintf("%d 个目标集群部署失败: %s", len(failed), strings.Join(failed, "; "))
+}
+
+// Unwrap 返回每个失败目标的错误，便于 errors.Is、errors.As 检查
+func (e *TargetsError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// Summary 生成每个目标集群结果的可读摘要，包含失败目标的诊断信息
+func (e *TargetsError) Summary() string {
+	var b strings.Builder
+	for _, r := range e.Results {
+		status := "成功"
+		switch {
+		case r.Skipped:
+			status = "跳过"
+		case r.Err != nil && r.RolledBack:
+			status = "失败，已回滚"
+		case r.Err != nil:
+			status = "失败"
+		case r.RolledBack:
+			status = "已回滚"
+		}
+		fmt.Fprintf(&b, "  - %s (%s): %s，耗时 %s\n", r.Target, r.Namespace, status, r.Duration.Round(time.Second))
+
+		var de *DeployError
+		if errors.As(r.Err, &de) && de.Diagnostics != nil {
+			for _, line := range strings.Split(de.Diagnostics.Summary(), "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// targetConfig 单个目标集群的部署配置
+type targetConfig struct {
+	name   string
+	config *DeployConfig
+}
+
+// label 返回用于错误信息的目标描述，单集群时为空
+func (tc *targetConfig) label() string {
+	if tc.name == "" {
+		return ""
+	}
+	return fmt.Sprintf("（目标 %s）", tc.name)
+}
+
+// targetConfigs 为每个目标集群生成经 prepareK8s 补全的独立部署配置，未配置目标时只有一个无名目标
+func (uc *DeployUsecase) targetConfigs(config *DeployConfig) []*targetConfig {
+	if len(config.K8s.Targets) == 0 {
+		return []*targetConfig{{config: uc.prepareK8s(config)}}
+	}
+
+	var result []*targetConfig
+	for i, target := range config.K8s.Targets {
+		k8s := *config.K8s
+		k8s.Targets = nil
+		if target.KubeconfigPath != "" || target.Kubeconfig != "" || target.Server != "" {
+			// 外层的集群地址、令牌与 CA 属于另一个集群，不能沿用
+			k8s.InCluster = false
+			k8s.KubeconfigPath = target.KubeconfigPath
+			k8s.Kubeconfig = target.Kubeconfig
+			k8s.Server = target.Server
+			k8s.Token = target.Token
+			k8s.TokenFile = target.TokenFile
+			k8s.CAFile = target.CAFile
+			k8s.CAData = target.CAData
+			k8s.InsecureSkipTLSVerify = target.InsecureSkipTLSVerify
+		}
+		if target.Context != "" {
+			k8s.Context = target.Context
+		}
+		if target.Namespace != "" {
+			k8s.Namespace = target.Namespace
+		}
+
+		name := target.Name
+		if name == "" {
+			name = target.Context
+		}
+		if name == "" {
+			name = fmt.Sprintf("target-%d", i+1)
+		}
+
+		deployConfig := *config
+		deployConfig.K8s = &k8s
+		result = append(result, &targetConfig{name: name, config: uc.prepareK8s(&deployConfig)})
+	}
+	return result
+}
+
+// deployTargets 按配置顺序或并行部署到多个目标集群，并按失败策略处理
+func (uc *DeployUsecase) deployTargets(ctx context.Context, config *DeployConfig) error {
+	policy := config.K8s.FailurePolicy
+	if policy == "" {
+		policy = FailureStop
+	}
+	targets := uc.targetConfigs(config)
+	results := make([]*TargetResult, len(targets))
+	for i, tc := range targets {
+		results[i] = &TargetResult{Target: tc.name, Namespace: tc.config.K8s.Namespace, Skipped: true}
+	}
+
+	uc.log.WithContext(ctx).Infof("开始部署到 %d 个目标集群，并行: %v，失败策略: %s", len(targets), config.K8s.Parallel, policy)
+
+	deployCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 回滚策略下记录部署前的版本号，只回滚本次部署改变了版本的目标；-1 表示未知
+	before := make([]int64, len(targets))
+
+	deployOne := func(i int) {
+		tc := targets[i]
+		result := results[i]
+		result.Skipped = false
+		if policy == FailureRollback {
+			revision, err := uc.repo.GetK8sRevision(deployCtx, tc.config.K8s)
+			if err != nil {
+				uc.warn(ctx, "获取目标集群 %s 部署前的 Deployment 版本号失败，该目标失败时不会回滚: %v", tc.name, err)
+				revision = -1
+			}
+			before[i] = revision
+		}
+		start := time.Now()
+		uc.log.WithContext(ctx).Infof("开始部署目标集群: %s", tc.name)
+		result.Err = uc.deployCluster(deployCtx, tc.config)
+		result.Duration = time.Since(start)
+		if result.Err != nil {
+			uc.log.WithContext(ctx).Errorf("目标集群 %s 部署失败: %v", tc.name, result.Err)
+			// 停止策略下取消其余目标
+			if policy == FailureStop {
+				cancel()
+			}
+		}
+	}
+
+	if config.K8s.Parallel {
+		var wg sync.WaitGroup
+		for i := range targets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				deployOne(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range targets {
+			if deployCtx.Err() != nil {
+				break
+			}
+			deployOne(i)
+			if results[i].Err != nil && policy != FailureContinue {
+				break
+			}
+		}
+	}
+
+	failed := slices.ContainsFunc(results, func(r *TargetResult) bool { return r.Err != nil })
+
+	// 回滚策略下回滚本次部署改变了 Deployment 版本的目标
+	if failed && policy == FailureRollback {
+		for i, tc := range targets {
+			if results[i].Skipped || before[i] < 0 {
+				continue
+			}
+			after, err := uc.repo.GetK8sRevision(ctx, tc.config.K8s)
+			if err != nil {
+				uc.log.WithContext(ctx).Errorf("获取目标集群 %s 的 Deployment 版本号失败，跳过回滚: %v", tc.name, err)
+				continue
+			}
+			if after == before[i] {
+				uc.log.WithContext(ctx).Infof("目标集群 %s 的 Deployment 版本未变化，无需回滚", tc.name)
+				continue
+			}
+			if before[i] == 0 {
+				uc.warn(ctx, "目标集群 %s 的 Deployment 由本次部署创建，没有可回滚的版本", tc.name)
+				continue
+			}
+			uc.log.WithContext(ctx).Infof("回滚目标集群: %s", tc.name)
+			if err := uc.repo.RollbackK8sDeployment(ctx, tc.config.K8s); err != nil {
+				uc.log.WithContext(ctx).Errorf("回滚目标集群 %s 失败: %v", tc.name, err)
+				continue
+			}
+			results[i].RolledBack = true
+		}
+	}
+
+	if run := runFrom(ctx); run != nil {
+		run.setTargets(results)
+	}
+
+	targetsErr := &TargetsError{Results: results}
+	uc.log.WithContext(ctx).Infof("多集群部署结果:\n%s", targetsErr.Summary())
+	if failed {
+		return targetsErr
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestDeployTargetsRollback 回滚策略只回滚本次部署改变了 Deployment 版本的目标
+func TestDeployTargetsRollback(t *testing.T) {
+	repo := newFakeRepo()
+	repo.revisions = map[string]int64{"ns-a": 3, "ns-b": 3, "ns-c": 3}
+	// c 的 Pod 模板未变化，b 在更新 Deployment 之前失败
+	repo.unchanged["ns-c"] = true
+	repo.fail["manifests:ns-b"] = errors.New("清单无效")
+	uc := newTestUsecase(repo)
+
+	config := &DeployConfig{
+		ProjectName: "demo",
+		K8s: &K8sConfig{
+			Namespace:      "default",
+			DeploymentName: "demo",
+			Manifests:      &Manifests{},
+			FailurePolicy:  FailureRollback,
+			Targets: []*Target{
+				{Name: "a", Namespace: "ns-a"},
+				{Name: "c", Namespace: "ns-c"},
+				{Name: "b", Namespace: "ns-b"},
+			},
+		},
+	}
+
+	err := uc.deployK8s(context.Background(), config)
+	var te *TargetsError
+	if !errors.As(err, &te) {
+		t.Fatalf("deployK8s() error = %v, want TargetsError", err)
+	}
+
+	rolledBack := map[string]bool{}
+	for _, r := range te.Results {
+		rolledBack[r.Target] = r.RolledBack
+	}
+	want := map[string]bool{"a": true, "b": false, "c": false}
+	for name, w := range want {
+		if rolledBack[name] != w {
+			t.Errorf("目标 %s RolledBack = %v, want %v", name, rolledBack[name], w)
+		}
+	}
+	for _, call := range []string{"rollback:ns-b", "rollback:ns-c"} {
+		if repo.called(call) {
+			t.Errorf("不应调用 %s", call)
+		}
+	}
+}
+
+// TestTargetConfigsConnection 目标使用独立连接时不沿用外层的集群地址与认证信息
+func TestTargetConfigsConnection(t *testing.T) {
+	uc := newTestUsecase(newFakeRepo())
+	config := &DeployConfig{
+		K8s: &K8sConfig{
+			Namespace:             "default",
+			KubeconfigPath:        "/base/kubeconfig",
+			Server:                "https://base:6443",
+			TokenFile:             "/base/token",
+			CAFile:                "/base/ca.crt",
+			InsecureSkipTLSVerify: true,
+			Targets: []*Target{
+				{Name: "server", Server: "https://other:6443", TokenFile: "/other/token", CAData: "ca"},
+				{Name: "kubeconfig", KubeconfigPath: "/other/kubeconfig"},
+				{Name: "context", Context: "other"},
+			},
+		},
+	}
+
+	targets := uc.targetConfigs(config)
+
+	server := targets[0].config.K8s
+	if server.Server != "https://other:6443" || server.TokenFile != "/other/token" || server.CAData != "ca" {
+		t.Errorf("server 目标连接 = %+v", server)
+	}
+	if server.KubeconfigPath != "" || server.CAFile != "" || server.InsecureSkipTLSVerify {
+		t.Errorf("server 目标沿用了外层连接: kubeconfig=%q ca_file=%q insecure=%v", server.KubeconfigPath, server.CAFile, server.InsecureSkipTLSVerify)
+	}
+
+	kubeconfig := targets[1].config.K8s
+	if kubeconfig.KubeconfigPath != "/other/kubeconfig" || kubeconfig.Server != "" || kubeconfig.TokenFile != "" || kubeconfig.CAFile != "" {
+		t.Errorf("kubeconfig 目标连接 = %+v", kubeconfig)
+	}
+
+	// 仅切换上下文时沿用外层的 kubeconfig
+	switched := targets[2].config.K8s
+	if switched.KubeconfigPath != "/base/kubeconfig" || switched.Context != "other" {
+		t.Errorf("context 目标连接 = %+v", switched)
+	}
+}
+
+// TestTargetsErrorUnwrap errors.Is、errors.As 可检查各目标的错误
+func TestTargetsErrorUnwrap(t *testing.T) {
+	errA := errors.New("发布超时")
+	de := &DeployError{Err: errors.New("钩子失败")}
+	err := error(&TargetsError{Results: []*TargetResult{
+		{Target: "a", Err: errA},
+		{Target: "b"},
+		{Target: "c", Err: de},
+	}})
+
+	if !errors.Is(err, errA) {
+		t.Error("errors.Is 未找到目标 a 的错误")
+	}
+	var got *DeployError
+	if !errors.As(err, &got) || got != de {
+		t.Error("errors.As 未找到目标 c 的 DeployError")
+	}
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"go-drone-deploy/internal/biz"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultTargetCPUUtilization 未配置任何指标时使用的 CPU 目标利用率
+const defaultTargetCPUUtilization int32 = 80
+
+// ApplyK8sAutoscaler 应用 Kubernetes HorizontalPodAutoscaler
+func (r *deployRepo) ApplyK8sAutoscaler(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Infof("应用 Kubernetes HorizontalPodAutoscaler: %s", config.DeploymentName)
+
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	// 构建 HorizontalPodAutoscaler 对象
+	hpa, err := r.buildAutoscaler(config)
+	if err != nil {
+		return err
+	}
+
+	// 应用 HorizontalPodAutoscaler
+	hpaClient := clientset.AutoscalingV2().HorizontalPodAutoscalers(config.Namespace)
+	existing, err := hpaClient.Get(ctx, hpa.Name, metav1.GetOptions{})
+	if err != nil {
+		// HorizontalPodAutoscaler 不存在，创建新的
+		_, err = hpaClient.Create(ctx, hpa, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("创建 HorizontalPodAutoscaler 失败: %w", err)
+		}
+		r.log.WithContext(ctx).Info("HorizontalPodAutoscaler 创建成功")
+	} else {
+		// HorizontalPodAutoscaler 已存在，更新
+		hpa.ResourceVersion = existing.ResourceVersion
+		_, err = hpaClient.Update(ctx, hpa, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("更新 HorizontalPodAutoscaler 失败: %w", err)
+		}
+		r.log.WithContext(ctx).Info("HorizontalPodAutoscaler 更新成功")
+	}
+
+	return nil
+}
+
+// ApplyK8sPodDisruptionBudget 应用 Kubernetes PodDisruptionBudget
+func (r *deployRepo) ApplyK8sPodDisruptionBudget(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Infof("应用 Kubernetes PodDisruptionBudget: %s", config.DeploymentName)
+
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	// 构建 PodDisruptionBudget 对象
+	pdb, err := r.buildPodDisruptionBudget(config)
+	if err != nil {
+		return err
+	}
+
+	// 应用 PodDisruptionBudget
+	pdbClient := clientset.PolicyV1().PodDisruptionBudgets(config.Namespace)
+	existing, err := pdbClient.Get(ctx, pdb.Name, metav1.GetOptions{})
+	if err != nil {
+		// PodDisruptionBudget 不存在，创建新的
+		_, err = pdbClient.Create(ctx, pdb, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("创建 PodDisruptionBudget 失败: %w", err)
+		}
+		r.log.WithContext(ctx).Info("PodDisruptionBudget 创建成功")
+	} else {
+		// PodDisruptionBudget 已存在，更新
+		pdb.ResourceVersion = existing.ResourceVersion
+		_, err = pdbClient.Update(ctx, pdb, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("更新 PodDisruptionBudget 失败: %w", err)
+		}
+		r.log.WithContext(ctx).Info("PodDisruptionBudget 更新成功")
+	}
+
+	return nil
+}
+
+// buildAutoscaler 构建 HorizontalPodAutoscaler 对象
+func (r *deployRepo) buildAutoscaler(config *biz.K8sConfig) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	as := config.Autoscaling
+	if as.MaxReplicas <= 0 {
+		return nil, fmt.Errorf("HPA 最大副本数必须大于 0")
+	}
+	if as.MinReplicas > as.MaxReplicas {
+		return nil, fmt.Errorf("HPA 最小副本数 %d 大于最大副本数 %d", as.MinReplicas, as.MaxReplicas)
+	}
+
+	labels := objectLabels(config)
+
+	// 构建指标，未配置时默认按 CPU 利用率扩缩容
+	cpuTarget := as.TargetCPUUtilizationPercentage
+	if cpuTarget == 0 && as.TargetMemoryUtilizationPercentage == 0 {
+		cpuTarget = defaultTargetCPUUtilization
+	}
+	var metrics []autoscalingv2.MetricSpec
+	if cpuTarget > 0 {
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, cpuTarget))
+	}
+	if as.TargetMemoryUtilizationPercentage > 0 {
+		metrics = append(metrics, resourceMetric(corev1.ResourceMemory, as.TargetMemoryUtilizationPercentage))
+	}
+
+	var minReplicas *int32
+	if as.MinReplicas > 0 {
+		minReplicas = &as.MinReplicas
+	}
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        config.DeploymentName,
+			Namespace:   config.Namespace,
+			Labels:      labels,
+			Annotations: objectAnnotations(config),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       config.DeploymentName,
+			},
+			MinReplicas: minReplicas,
+			MaxReplicas: as.MaxReplicas,
+			Metrics:     metrics,
+		},
+	}, nil
+}
+
+// resourceMetric 构建按平均利用率计算的资源指标
+func resourceMetric(name corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+// buildPodDisruptionBudget 构建 PodDisruptionBudget 对象
+func (r *deployRepo) buildPodDisruptionBudget(config *biz.K8sConfig) (*policyv1.PodDisruptionBudget, error) {
+	pd := config.Disruption
+	if (pd.MinAvailable == "") == (pd.MaxUnavailable == "") {
+		return nil, fmt.Errorf("PDB 的 min_available 与 max_unavailable 必须且只能设置其一")
+	}
+
+	labels := objectLabels(config)
+
+	spec := policyv1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{
+			MatchLabels: selectorLabels(config),
+		},
+	}
+	if pd.MinAvailable != "" {
+		v := intstr.Parse(pd.MinAvailable)
+		spec.MinAvailable = &v
+	}
+	if pd.MaxUnavailable != "" {
+		v := intstr.Parse(pd.MaxUnavailable)
+		spec.MaxUnavailable = &v
+	}
+
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        config.DeploymentName,
+			Namespace:   config.Namespace,
+			Labels:      labels,
+			Annotations: objectAnnotations(config),
+		},
+		Spec: spec,
+	}, nil
+}
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"fmt"
+
+	"go-drone-deploy/internal/biz"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// buildContainers 构建附加容器（init 容器或 sidecar），未指定镜像时使用主容器镜像
+func buildContainers(containers []*biz.Container, config *biz.K8sConfig) ([]corev1.Container, error) {
+	var result []corev1.Container
+	for _, c := range containers {
+		image := c.Image
+		if image == "" {
+			image = config.Image
+		}
+		resources, err := buildResources(c.Resources)
+		if err != nil {
+			return nil, fmt.Errorf("容器 %s 的资源配置无效: %w", c.Name, err)
+		}
+		result = append(result, corev1.Container{
+			Name:            c.Name,
+			Image:           image,
+			Command:         c.Command,
+			Args:            c.Args,
+			Ports:           buildContainerPorts(c.Ports),
+			Env:             buildEnvVars(c.EnvVars),
+			Resources:       resources,
+			VolumeMounts:    buildVolumeMounts(c.VolumeMounts),
+			SecurityContext: buildContainerSecurityContext(config.SecurityContext),
+		})
+	}
+	return result, nil
+}
+
+// buildContainerPorts 构建容器端口，未设置 TargetPort 时使用 Port
+func buildContainerPorts(ports []*biz.Port) []corev1.ContainerPort {
+	var result []corev1.ContainerPort
+	for _, port := range ports {
+		containerPort := port.TargetPort
+		if containerPort == 0 {
+			containerPort = port.Port
+		}
+		result = append(result, corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: containerPort,
+			Protocol:      corev1.Protocol(port.Protocol),
+		})
+	}
+	return result
+}
+
+// buildResources 构建资源请求与限制，无效的资源数量返回错误
+func buildResources(config *biz.Resources) (corev1.ResourceRequirements, error) {
+	resources := corev1.ResourceRequirements{}
+	if config == nil {
+		return resources, nil
+	}
+
+	var err error
+	resources.Requests, err = buildQuantities("requests", map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    config.CPURequest,
+		corev1.ResourceMemory: config.MemoryRequest,
+	})
+	if err != nil {
+		return resources, err
+	}
+	resources.Limits, err = buildQuantities("limits", map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    config.CPULimit,
+		corev1.ResourceMemory: config.MemoryLimit,
+	})
+	if err != nil {
+		return resources, err
+	}
+	return resources, nil
+}
+
+// buildQuantities 解析 requests 或 limits 下的资源数量，忽略空值，全部为空时返回 nil
+func buildQuantities(kind string, values map[corev1.ResourceName]string) (corev1.ResourceList, error) {
+	var list corev1.ResourceList
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s 的取值 %q 无效: %w", kind, name, value, err)
+		}
+		if list == nil {
+			list = corev1.ResourceList{}
+		}
+		list[name] = q
+	}
+	return list, nil
+}
+
+// buildVolumeMounts 构建卷挂载
+func buildVolumeMounts(mounts []*biz.VolumeMount) []corev1.VolumeMount {
+	var result []corev1.VolumeMount
+	for _, m := range mounts {
+		result = append(result, corev1.VolumeMount{
+			Name:      m.Name,
+			MountPath: m.MountPath,
+			SubPath:   m.SubPath,
+			ReadOnly:  m.ReadOnly,
+		})
+	}
+	return result
+}
+
+// buildVolumes 构建 Pod 卷
+func buildVolumes(volumes []*biz.Volume) []corev1.Volume {
+	var result []corev1.Volume
+	for _, v := range volumes {
+		volume := corev1.Volume{Name: v.Name}
+		switch {
+		case v.ConfigMap != "":
+			volume.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: v.ConfigMap},
+			}
+		case v.Secret != "":
+			volume.Secret = &corev1.SecretVolumeSource{SecretName: v.Secret}
+		case v.PersistentVolumeClaim != "":
+			volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: v.PersistentVolumeClaim,
+				ReadOnly:  v.ReadOnly,
+			}
+		default:
+			// 未指定来源时使用 emptyDir
+			emptyDir := &corev1.EmptyDirVolumeSource{}
+			if v.EmptyDir != nil {
+				emptyDir.Medium = corev1.StorageMedium(v.EmptyDir.Medium)
+				if v.EmptyDir.SizeLimit != "" {
+					if q, err := resource.ParseQuantity(v.EmptyDir.SizeLimit); err == nil {
+						emptyDir.SizeLimit = &q
+					}
+				}
+			}
+			volume.EmptyDir = emptyDir
+		}
+		result = append(result, volume)
+	}
+	return result
+}
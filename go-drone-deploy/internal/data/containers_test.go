@@ -0,0 +1,41 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// TestBuildResources 无效的资源数量返回错误，不再静默部署为 0
+func TestBuildResources(t *testing.T) {
+	resources, err := buildResources(&biz.Resources{CPURequest: "100m", MemoryLimit: "256Mi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resources.Requests.Cpu().String(); got != "100m" {
+		t.Errorf("requests.cpu = %s, want 100m", got)
+	}
+	if got := resources.Limits.Memory().String(); got != "256Mi" {
+		t.Errorf("limits.memory = %s, want 256Mi", got)
+	}
+	if len(resources.Requests) != 1 {
+		t.Errorf("requests = %v, 未配置的资源不应出现", resources.Requests)
+	}
+
+	_, err = buildResources(&biz.Resources{CPULimit: "abc"})
+	if err == nil || !strings.Contains(err.Error(), "limits.cpu") {
+		t.Fatalf("buildResources() error = %v, want limits.cpu 无效", err)
+	}
+}
+
+// TestBuildDeploymentInvalidResources 附加容器的资源配置无效时构建 Deployment 失败
+func TestBuildDeploymentInvalidResources(t *testing.T) {
+	repo, _ := newTestRepo(t, nil)
+	config := rolloutTestConfig()
+	config.Sidecars = []*biz.Container{{Name: "proxy", Resources: &biz.Resources{MemoryRequest: "lots"}}}
+
+	if _, err := repo.buildDeployment(config); err == nil || !strings.Contains(err.Error(), "proxy") {
+		t.Fatalf("buildDeployment() error = %v, want 指向容器 proxy", err)
+	}
+}
@@ -1,24 +1,71 @@
 package data
 
 import (
+	"net/http"
+	"sync"
+	"time"
+
 	"go-drone-deploy/internal/conf"
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/google/wire"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 // ProviderSet is data providers.
 var ProviderSet = wire.NewSet(NewData, NewGreeterRepo)
 
+// defaultHTTPTimeout 通知等 HTTP 请求单次发送的默认超时时间，由发送时的上下文控制
+const defaultHTTPTimeout = 10 * time.Second
+
 // Data .
 type Data struct {
-	// TODO wrapped database client
+	log *log.Helper
+	// httpClient 发送通知等外部 HTTP 请求使用的客户端
+	httpClient *http.Client
+
+	mu sync.Mutex
+	// k8s 按集群连接配置缓存的 Kubernetes 客户端
+	k8s map[k8sClientKey]*k8sClients
+	// k8sOverride 注入的客户端，设置后所有集群都使用它
+	k8sOverride *k8sClients
+}
+
+// Option 数据层选项
+type Option func(*Data)
+
+// WithK8sClients 注入 Kubernetes 客户端，所有集群操作都使用该客户端，
+// 可传入 k8s.io/client-go/kubernetes/fake 及 dynamic/fake 的实现用于测试
+func WithK8sClients(clientset kubernetes.Interface, dynamicClient dynamic.Interface, mapper meta.ResettableRESTMapper) Option {
+	return func(d *Data) {
+		d.k8sOverride = &k8sClients{clientset: clientset, dynamic: dynamicClient, mapper: mapper}
+	}
+}
+
+// WithHTTPClient 替换外部 HTTP 请求使用的客户端
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Data) {
+		d.httpClient = client
+	}
 }
 
 // NewData .
-func NewData(c *conf.Data, logger log.Logger) (*Data, func(), error) {
+func NewData(c *conf.Data, logger log.Logger, opts ...Option) (*Data, func(), error) {
+	// httpClient 不设置客户端超时，每次发送的超时由 NotifyConfig.Timeout 通过上下文控制
+	d := &Data{
+		log:        log.NewHelper(logger),
+		httpClient: &http.Client{},
+		k8s:        map[k8sClientKey]*k8sClients{},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
 	cleanup := func() {
-		log.NewHelper(logger).Info("closing the data resources")
+		d.log.Info("closing the data resources")
+		d.closeK8sClients()
 	}
-	return &Data{}, cleanup, nil
+	return d, cleanup, nil
 }
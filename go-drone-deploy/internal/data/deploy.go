@@ -1,25 +1,25 @@
 package data
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"go-drone-deploy/internal/biz"
 
 	"github.com/go-kratos/kratos/v2/log"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 // deployRepo 部署仓库实现
@@ -59,7 +59,7 @@ func (r *deployRepo) BuildDockerImage(ctx context.Context, config *biz.DockerCon
 }
 
 // PushDockerImage 推送 Docker 镜像
-func (r *deployRepo) PushDockerImage(ctx context.Context, config *biz.DockerConfig) error {
+func (r *deployRepo) PushDockerImage(ctx context.Context, config *biz.DockerConfig) (string, error) {
 	r.log.WithContext(ctx).Infof("推送 Docker 镜像: %s", config.ImageName)
 
 	// Docker 登录
@@ -70,7 +70,7 @@ func (r *deployRepo) PushDockerImage(ctx context.Context, config *biz.DockerConf
 			config.Registry,
 		)
 		if err := loginCmd.Run(); err != nil {
-			return fmt.Errorf("Docker 登录失败: %w", err)
+			return "", fmt.Errorf("Docker 登录失败: %w", err)
 		}
 	}
 
@@ -80,11 +80,23 @@ func (r *deployRepo) PushDockerImage(ctx context.Context, config *biz.DockerConf
 	pushCmd.Stderr = os.Stderr
 
 	if err := pushCmd.Run(); err != nil {
-		return fmt.Errorf("推送 Docker 镜像失败: %w", err)
+		return "", fmt.Errorf("推送 Docker 镜像失败: %w", err)
 	}
 
-	r.log.WithContext(ctx).Info("Docker 镜像推送成功")
-	return nil
+	// 获取推送后的镜像摘要
+	inspectCmd := exec.CommandContext(ctx, "docker", "image", "inspect",
+		"--format", "{{index .RepoDigests 0}}",
+		config.ImageName,
+	)
+	output, err := inspectCmd.Output()
+	if err != nil {
+		r.log.WithContext(ctx).Warnf("获取镜像摘要失败: %v", err)
+		return "", nil
+	}
+	digest := strings.TrimSpace(string(output))
+
+	r.log.WithContext(ctx).Infof("Docker 镜像推送成功: %s", digest)
+	return digest, nil
 }
 
 // ApplyK8sDeployment 应用 Kubernetes Deployment
@@ -92,17 +104,20 @@ func (r *deployRepo) ApplyK8sDeployment(ctx context.Context, config *biz.K8sConf
 	r.log.WithContext(ctx).Infof("应用 Kubernetes Deployment: %s", config.DeploymentName)
 
 	// 创建 Kubernetes 客户端
-	clientset, err := r.createK8sClient(config.KubeconfigPath)
+	clientset, err := r.createK8sClient(config)
 	if err != nil {
 		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
 	}
 
 	// 构建 Deployment 对象
-	deployment := r.buildDeployment(config)
+	deployment, err := r.buildDeployment(config)
+	if err != nil {
+		return err
+	}
 
 	// 应用 Deployment
 	deploymentsClient := clientset.AppsV1().Deployments(config.Namespace)
-	_, err = deploymentsClient.Get(ctx, config.DeploymentName, metav1.GetOptions{})
+	existing, err := deploymentsClient.Get(ctx, config.DeploymentName, metav1.GetOptions{})
 	if err != nil {
 		// Deployment 不存在，创建新的
 		_, err = deploymentsClient.Create(ctx, deployment, metav1.CreateOptions{})
@@ -112,6 +127,10 @@ func (r *deployRepo) ApplyK8sDeployment(ctx context.Context, config *biz.K8sConf
 		r.log.WithContext(ctx).Info("Deployment 创建成功")
 	} else {
 		// Deployment 已存在，更新
+		// 副本数由 HPA 管理时保留当前值，避免覆盖自动扩缩容的结果
+		if config.Autoscaling != nil {
+			deployment.Spec.Replicas = existing.Spec.Replicas
+		}
 		_, err = deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{})
 		if err != nil {
 			return fmt.Errorf("更新 Deployment 失败: %w", err)
@@ -127,7 +146,7 @@ func (r *deployRepo) ApplyK8sService(ctx context.Context, config *biz.K8sConfig)
 	r.log.WithContext(ctx).Infof("应用 Kubernetes Service: %s", config.ServiceName)
 
 	// 创建 Kubernetes 客户端
-	clientset, err := r.createK8sClient(config.KubeconfigPath)
+	clientset, err := r.createK8sClient(config)
 	if err != nil {
 		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
 	}
@@ -157,188 +176,180 @@ func (r *deployRepo) ApplyK8sService(ctx context.Context, config *biz.K8sConfig)
 	return nil
 }
 
-// UpdateK8sVersion 更新 Kubernetes 版本
-func (r *deployRepo) UpdateK8sVersion(ctx context.Context, config *biz.K8sConfig) error {
-	r.log.WithContext(ctx).Info("更新 Kubernetes 版本")
+// SetK8sImage 只更新 Deployment 主容器的镜像及版本标签，不改动其余配置
+func (r *deployRepo) SetK8sImage(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Infof("更新 Kubernetes Deployment 镜像: %s", config.Image)
+
+	if config.Image == "" {
+		return fmt.Errorf("镜像为空")
+	}
 
 	// 创建 Kubernetes 客户端
-	clientset, err := r.createK8sClient(config.KubeconfigPath)
+	clientset, err := r.createK8sClient(config)
 	if err != nil {
 		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
 	}
 
-	// 重启 Deployment 以应用新版本
-	deploymentsClient := clientset.AppsV1().Deployments(config.Namespace)
-	deployment, err := deploymentsClient.Get(ctx, config.DeploymentName, metav1.GetOptions{})
+	// 按容器名称合并，只替换主容器的镜像
+	labels := versionLabels(config)
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": labels,
+				},
+				"spec": map[string]interface{}{
+					"containers": []map[string]interface{}{
+						{"name": config.DeploymentName, "image": config.Image},
+					},
+				},
+			},
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("获取 Deployment 失败: %w", err)
+		return fmt.Errorf("序列化镜像补丁失败: %w", err)
 	}
 
-	// 添加重启注解
-	if deployment.Spec.Template.Annotations == nil {
-		deployment.Spec.Template.Annotations = make(map[string]string)
-	}
-	deployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = metav1.Now().Format("2006-01-02T15:04:05Z")
-
-	_, err = deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{})
+	_, err = clientset.AppsV1().Deployments(config.Namespace).Patch(ctx, config.DeploymentName,
+		types.StrategicMergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager})
 	if err != nil {
-		return fmt.Errorf("重启 Deployment 失败: %w", err)
+		return fmt.Errorf("更新 Deployment 镜像失败: %w", err)
 	}
 
-	r.log.WithContext(ctx).Info("Kubernetes 版本更新成功")
+	r.log.WithContext(ctx).Info("Kubernetes Deployment 镜像更新成功")
 	return nil
 }
 
-// SendNotification 发送通知
-func (r *deployRepo) SendNotification(ctx context.Context, config *biz.NotifyConfig, message string) error {
-	r.log.WithContext(ctx).Infof("发送通知: %s", message)
-
-	if config.WebhookURL == "" {
-		return fmt.Errorf("Webhook URL 为空")
-	}
-
-	// 构建通知消息
-	payload := map[string]interface{}{
-		"text":    message,
-		"channel": config.Channel,
-	}
+// RestartK8sDeployment 通过更新 Pod 模板的重启注解滚动重启 Deployment，与 kubectl rollout restart 一致
+func (r *deployRepo) RestartK8sDeployment(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Infof("重启 Kubernetes Deployment: %s", config.DeploymentName)
 
-	jsonData, err := json.Marshal(payload)
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
 	if err != nil {
-		return fmt.Errorf("序列化通知消息失败: %w", err)
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
 	}
 
-	// 发送 HTTP 请求
-	resp, err := http.Post(config.WebhookURL, "application/json", bytes.NewBuffer(jsonData))
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						restartedAtAnnotation: time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("发送通知请求失败: %w", err)
+		return fmt.Errorf("序列化重启补丁失败: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("通知发送失败，状态码: %d", resp.StatusCode)
+	_, err = clientset.AppsV1().Deployments(config.Namespace).Patch(ctx, config.DeploymentName,
+		types.StrategicMergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager})
+	if err != nil {
+		return fmt.Errorf("重启 Deployment 失败: %w", err)
 	}
 
-	r.log.WithContext(ctx).Info("通知发送成功")
+	r.log.WithContext(ctx).Info("Kubernetes Deployment 重启已触发")
 	return nil
 }
 
-// createK8sClient 创建 Kubernetes 客户端
-func (r *deployRepo) createK8sClient(kubeconfigPath string) (*kubernetes.Clientset, error) {
-	var config clientcmd.ClientConfig
-
-	if kubeconfigPath != "" {
-		// 使用指定的 kubeconfig 文件
-		config = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath},
-			&clientcmd.ConfigOverrides{},
-		)
-	} else {
-		// 使用默认的 kubeconfig
-		kubeconfigPath = filepath.Join(os.Getenv("HOME"), ".kube", "config")
-		config = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath},
-			&clientcmd.ConfigOverrides{},
-		)
-	}
-
-	restConfig, err := config.ClientConfig()
+// createK8sClient 获取 Kubernetes 客户端，同一集群的客户端在本次运行中复用
+func (r *deployRepo) createK8sClient(config *biz.K8sConfig) (kubernetes.Interface, error) {
+	clients, err := r.data.k8sClients(config)
 	if err != nil {
-		return nil, fmt.Errorf("加载 kubeconfig 失败: %w", err)
+		return nil, err
 	}
+	return clients.clientset, nil
+}
 
-	clientset, err := kubernetes.NewForConfig(restConfig)
+// createDynamicClient 获取 Kubernetes 动态客户端及 REST 映射
+func (r *deployRepo) createDynamicClient(config *biz.K8sConfig) (dynamic.Interface, meta.ResettableRESTMapper, error) {
+	clients, err := r.data.k8sClients(config)
 	if err != nil {
-		return nil, fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+		return nil, nil, err
 	}
-
-	return clientset, nil
+	return clients.dynamic, clients.mapper, nil
 }
 
 // buildDeployment 构建 Deployment 对象
-func (r *deployRepo) buildDeployment(config *biz.K8sConfig) *appsv1.Deployment {
-	labels := map[string]string{
-		"app": config.DeploymentName,
-	}
+func (r *deployRepo) buildDeployment(config *biz.K8sConfig) (*appsv1.Deployment, error) {
+	selector := selectorLabels(config)
+	labels := objectLabels(config)
 
-	// 构建环境变量
-	var envVars []corev1.EnvVar
-	for _, env := range config.EnvVars {
-		envVars = append(envVars, corev1.EnvVar{
-			Name:  env.Name,
-			Value: env.Value,
-		})
+	resources, err := buildResources(config.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("资源配置无效: %w", err)
 	}
-
-	// 构建端口
-	var ports []corev1.ContainerPort
-	for _, port := range config.Ports {
-		ports = append(ports, corev1.ContainerPort{
-			Name:          port.Name,
-			ContainerPort: port.TargetPort,
-			Protocol:      corev1.Protocol(port.Protocol),
-		})
+	initContainers, err := buildContainers(config.InitContainers, config)
+	if err != nil {
+		return nil, err
+	}
+	sidecars, err := buildContainers(config.Sidecars, config)
+	if err != nil {
+		return nil, err
 	}
 
-	// 构建资源限制
-	resources := corev1.ResourceRequirements{}
-	if config.Resources != nil {
-		if config.Resources.CPURequest != "" || config.Resources.MemoryRequest != "" {
-			resources.Requests = corev1.ResourceList{}
-			if config.Resources.CPURequest != "" {
-				resources.Requests[corev1.ResourceCPU] = *parseQuantity(config.Resources.CPURequest)
-			}
-			if config.Resources.MemoryRequest != "" {
-				resources.Requests[corev1.ResourceMemory] = *parseQuantity(config.Resources.MemoryRequest)
-			}
-		}
-		if config.Resources.CPULimit != "" || config.Resources.MemoryLimit != "" {
-			resources.Limits = corev1.ResourceList{}
-			if config.Resources.CPULimit != "" {
-				resources.Limits[corev1.ResourceCPU] = *parseQuantity(config.Resources.CPULimit)
-			}
-			if config.Resources.MemoryLimit != "" {
-				resources.Limits[corev1.ResourceMemory] = *parseQuantity(config.Resources.MemoryLimit)
-			}
-		}
+	// 启用 HPA 时以最小副本数作为初始副本数
+	replicas := config.Replicas
+	if config.Autoscaling != nil && config.Autoscaling.MinReplicas > 0 {
+		replicas = config.Autoscaling.MinReplicas
 	}
 
-	return &appsv1.Deployment{
+	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      config.DeploymentName,
-			Namespace: config.Namespace,
-			Labels:    labels,
+			Name:        config.DeploymentName,
+			Namespace:   config.Namespace,
+			Labels:      labels,
+			Annotations: objectAnnotations(config),
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &config.Replicas,
+			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: labels,
+				MatchLabels: selector,
 			},
+			Strategy: buildStrategy(config.Strategy),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      labels,
+					Annotations: config.PodAnnotations,
 				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
+					ServiceAccountName:        config.ServiceAccountName,
+					ImagePullSecrets:          buildImagePullSecrets(config.ImagePullSecrets),
+					NodeSelector:              config.NodeSelector,
+					Tolerations:               buildTolerations(config.Tolerations),
+					Affinity:                  buildAffinity(config.Affinity, selector),
+					TopologySpreadConstraints: buildTopologySpread(config.TopologySpreadConstraints, selector),
+					SecurityContext:           buildPodSecurityContext(config.SecurityContext),
+					InitContainers:            initContainers,
+					Containers: append([]corev1.Container{
 						{
-							Name:      config.DeploymentName,
-							Image:     "", // 这里需要从 Docker 配置中获取
-							Ports:     ports,
-							Env:       envVars,
-							Resources: resources,
+							Name:            config.DeploymentName,
+							Image:           config.Image,
+							Ports:           buildContainerPorts(config.Ports),
+							Env:             buildEnvVars(config.EnvVars),
+							Resources:       resources,
+							VolumeMounts:    buildVolumeMounts(config.VolumeMounts),
+							SecurityContext: buildContainerSecurityContext(config.SecurityContext),
 						},
-					},
+					}, sidecars...),
+					Volumes: buildVolumes(config.Volumes),
 				},
 			},
 		},
 	}
+	return deployment, nil
 }
 
 // buildService 构建 Service 对象
 func (r *deployRepo) buildService(config *biz.K8sConfig) *corev1.Service {
-	labels := map[string]string{
-		"app": config.DeploymentName,
-	}
+	selector := selectorLabels(config)
+	labels := objectLabels(config)
 
 	// 构建端口
 	var ports []corev1.ServicePort
@@ -353,21 +364,15 @@ func (r *deployRepo) buildService(config *biz.K8sConfig) *corev1.Service {
 
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      config.ServiceName,
-			Namespace: config.Namespace,
-			Labels:    labels,
+			Name:        config.ServiceName,
+			Namespace:   config.Namespace,
+			Labels:      labels,
+			Annotations: objectAnnotations(config),
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: labels,
+			Selector: selector,
 			Ports:    ports,
 			Type:     corev1.ServiceTypeClusterIP,
 		},
 	}
 }
-
-// parseQuantity 解析资源数量（简化版本）
-func parseQuantity(s string) *resource.Quantity {
-	// 这里应该使用 k8s.io/apimachinery/pkg/api/resource.ParseQuantity
-	// 为了简化，这里返回一个空的 Quantity
-	return &resource.Quantity{}
-}
\ No newline at end of file
@@ -0,0 +1,311 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go-drone-deploy/internal/biz"
+
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// fieldManager 服务端 apply 使用的字段管理者名称
+	fieldManager = "go-drone-deploy"
+	// redacted Secret 值脱敏后的占位符
+	redacted = "***"
+)
+
+// DiffK8s 以服务端 dry-run 计算全部资源与集群现状的差异，dry-run 使用与部署相同的请求：
+// 额外清单使用服务端 apply，Deployment、Service、HPA、PDB 使用创建或整体更新；配置了命名空间初始化时包含初始化的对象
+func (r *deployRepo) DiffK8s(ctx context.Context, config *biz.K8sConfig, docker *biz.DockerConfig) ([]*biz.ResourceDiff, error) {
+	r.log.WithContext(ctx).Infof("对比 Kubernetes 资源: %s", config.DeploymentName)
+
+	// 创建 Kubernetes 动态客户端
+	dynamicClient, mapper, err := r.createDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	// 构建全部对象，按部署时的顺序对比：额外清单在前，生成的资源在后
+	manifests, err := r.loadManifests(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	sortObjects(manifests)
+	resources, err := r.buildResources(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []*biz.ResourceDiff
+	if config.Bootstrap != nil {
+		if diffs, err = r.diffBootstrap(ctx, dynamicClient, mapper, config, docker); err != nil {
+			return nil, err
+		}
+	}
+	for _, obj := range manifests {
+		d, err := r.diffObject(ctx, dynamicClient, mapper, obj)
+		if err != nil {
+			return nil, fmt.Errorf("对比 %s 失败: %w", objectID(obj), err)
+		}
+		diffs = append(diffs, d)
+	}
+	for _, obj := range resources {
+		// 副本数由 HPA 管理时沿用现有副本数，与实际部署的行为一致
+		preserveReplicas := config.Autoscaling != nil && obj.GetKind() == "Deployment"
+		d, err := r.diffReplace(ctx, dynamicClient, mapper, obj, preserveReplicas)
+		if err != nil {
+			return nil, fmt.Errorf("对比 %s 失败: %w", objectID(obj), err)
+		}
+		diffs = append(diffs, d)
+	}
+	objects := append(resources, manifests...)
+
+	// 启用清理时列出将被删除的对象
+	if config.Prune {
+		candidates, _, err := r.pruneCandidates(ctx, dynamicClient, mapper, config, objects)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range candidates {
+			before := normalizeObject(obj)
+			redactSecret(before, nil)
+			diffText, err := unifiedDiff(before, nil, objectID(obj))
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, &biz.ResourceDiff{
+				Kind:      obj.GetKind(),
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+				Action:    biz.DiffDelete,
+				Diff:      diffText,
+			})
+		}
+	}
+
+	return diffs, nil
+}
+
+// diffObject 以服务端 dry-run apply 对比以服务端 apply 应用的对象
+func (r *deployRepo) diffObject(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	obj *unstructured.Unstructured) (*biz.ResourceDiff, error) {
+	ri, live, err := getLive(ctx, client, mapper, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// 服务端 dry-run apply，得到包含默认值的合并结果
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("序列化对象失败: %w", err)
+	}
+	force := true
+	merged, err := ri.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: fieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("服务端 dry-run 失败: %w", err)
+	}
+
+	return resourceDiff(obj, live, merged)
+}
+
+// diffReplace 以服务端 dry-run 创建或整体更新对比部署时以 Get 后 Create/Update 应用的对象，
+// 未写入配置的字段（如其它工具添加的注解）与部署时一样会被移除
+func (r *deployRepo) diffReplace(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	obj *unstructured.Unstructured, preserveReplicas bool) (*biz.ResourceDiff, error) {
+	ri, live, err := getLive(ctx, client, mapper, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged *unstructured.Unstructured
+	if live == nil {
+		merged, err = ri.Create(ctx, obj, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	} else {
+		desired := obj.DeepCopy()
+		desired.SetResourceVersion(live.GetResourceVersion())
+		if preserveReplicas {
+			if replicas, ok, _ := unstructured.NestedInt64(live.Object, "spec", "replicas"); ok {
+				_ = unstructured.SetNestedField(desired.Object, replicas, "spec", "replicas")
+			}
+		}
+		merged, err = ri.Update(ctx, desired, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("服务端 dry-run 失败: %w", err)
+	}
+
+	return resourceDiff(obj, live, merged)
+}
+
+// getLive 获取集群中的现有对象，不存在时为 nil
+func getLive(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	obj *unstructured.Unstructured) (dynamic.ResourceInterface, *unstructured.Unstructured, error) {
+	ri, err := resourceInterface(client, mapper, obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	live, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("获取现有对象失败: %w", err)
+		}
+		live = nil
+	}
+	return ri, live, nil
+}
+
+// resourceDiff 由现有对象和 dry-run 结果生成差异
+func resourceDiff(obj, live, merged *unstructured.Unstructured) (*biz.ResourceDiff, error) {
+	before, after := normalizeObject(live), normalizeObject(merged)
+	redactSecret(before, after)
+
+	diffText, err := unifiedDiff(before, after, objectID(obj))
+	if err != nil {
+		return nil, err
+	}
+
+	action := biz.DiffUpdate
+	switch {
+	case live == nil:
+		action = biz.DiffCreate
+	case diffText == "":
+		action = biz.DiffUnchanged
+	}
+
+	return &biz.ResourceDiff{
+		Kind:      obj.GetKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Action:    action,
+		Diff:      diffText,
+	}, nil
+}
+
+// resourceInterface 根据对象类型获取动态资源客户端
+func resourceInterface(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("查找资源类型 %s 失败: %w", gvk, err)
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+	}
+	// 集群级资源不带命名空间
+	obj.SetNamespace("")
+	return client.Resource(mapping.Resource), nil
+}
+
+// normalizeObject 去除由服务端维护、对比时无意义的字段
+func normalizeObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil {
+		return nil
+	}
+
+	out := obj.DeepCopy()
+	for _, field := range []string{"managedFields", "resourceVersion", "uid", "generation", "creationTimestamp", "selfLink"} {
+		unstructured.RemoveNestedField(out.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(out.Object, "metadata", "annotations", "deployment.kubernetes.io/revision")
+	for _, key := range deployAnnotations {
+		unstructured.RemoveNestedField(out.Object, "metadata", "annotations", key)
+	}
+	if len(out.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(out.Object, "metadata", "annotations")
+	}
+	unstructured.RemoveNestedField(out.Object, "status")
+	return out
+}
+
+// redactSecret 对 Secret 的值脱敏，保留“是否变化”的信息
+func redactSecret(before, after *unstructured.Unstructured) {
+	if !isSecret(before) && !isSecret(after) {
+		return
+	}
+
+	for _, field := range []string{"data", "stringData"} {
+		var beforeValues, afterValues map[string]interface{}
+		if before != nil {
+			beforeValues, _, _ = unstructured.NestedMap(before.Object, field)
+		}
+		if after != nil {
+			afterValues, _, _ = unstructured.NestedMap(after.Object, field)
+		}
+
+		if beforeValues != nil {
+			_ = unstructured.SetNestedMap(before.Object, redactValues(beforeValues, afterValues, " (before)"), field)
+		}
+		if afterValues != nil {
+			_ = unstructured.SetNestedMap(after.Object, redactValues(afterValues, beforeValues, " (after)"), field)
+		}
+	}
+}
+
+// redactValues 将值替换为占位符，与另一侧取值不同时附加后缀以便在 diff 中区分
+func redactValues(values, other map[string]interface{}, suffix string) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if otherValue, ok := other[key]; ok && otherValue != value {
+			result[key] = redacted + suffix
+		} else {
+			result[key] = redacted
+		}
+	}
+	return result
+}
+
+// isSecret 判断对象是否为 Secret
+func isSecret(obj *unstructured.Unstructured) bool {
+	return obj != nil && obj.GetKind() == "Secret"
+}
+
+// unifiedDiff 以 YAML 形式生成两个对象的统一 diff，无差异时返回空字符串
+func unifiedDiff(before, after *unstructured.Unstructured, name string) (string, error) {
+	beforeText, err := objectYAML(before)
+	if err != nil {
+		return "", err
+	}
+	afterText, err := objectYAML(after)
+	if err != nil {
+		return "", err
+	}
+	if beforeText == afterText {
+		return "", nil
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(beforeText),
+		B:        difflib.SplitLines(afterText),
+		FromFile: "live/" + name,
+		ToFile:   "desired/" + name,
+		Context:  3,
+	})
+}
+
+// objectYAML 将对象序列化为 YAML，对象为空时返回空字符串
+func objectYAML(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("序列化 %s 失败: %w", objectID(obj), err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	servicesGVR    = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+)
+
+// TestDiffK8sReplace Deployment 等生成的资源按部署时的整体更新对比：
+// 配置中没有的字段显示为将被移除，HPA 管理的副本数沿用现有值
+func TestDiffK8sReplace(t *testing.T) {
+	config := pruneTestConfig()
+	repo, _ := newTestRepo(t, nil)
+
+	desired, err := repo.buildDeployment(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, err := toUnstructured(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live.SetAnnotations(map[string]string{"other-tool/owner": "x"})
+	_ = unstructured.SetNestedField(live.Object, int64(4), "spec", "replicas")
+	live.SetResourceVersion("7")
+
+	repo, k := newTestRepo(t, nil, live)
+	// 假动态客户端不区分 dry-run；部署使用类型化客户端，动态客户端的创建与更新只来自对比，不写入存储
+	var requests []string
+	k.dynamic.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetVerb() != "create" && action.GetVerb() != "update" {
+			return false, nil, nil
+		}
+		requests = append(requests, action.GetVerb()+":"+action.GetResource().Resource)
+		obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if action.GetVerb() == "update" && obj.GetResourceVersion() != "7" {
+			t.Error("更新请求未携带现有对象的 resourceVersion")
+		}
+		return true, obj, nil
+	})
+
+	diffs, err := repo.DiffK8s(context.Background(), config, nil)
+	if err != nil {
+		t.Fatalf("DiffK8s() error = %v", err)
+	}
+
+	want := []string{"update:deployments", "create:services", "create:horizontalpodautoscalers"}
+	if strings.Join(requests, ",") != strings.Join(want, ",") {
+		t.Errorf("dry-run 请求 = %v, want %v", requests, want)
+	}
+
+	byKind := map[string]*biz.ResourceDiff{}
+	for _, d := range diffs {
+		byKind[d.Kind] = d
+	}
+	deployment := byKind["Deployment"]
+	if deployment == nil || deployment.Action != biz.DiffUpdate {
+		t.Fatalf("Deployment diff = %+v, want update", deployment)
+	}
+	if !strings.Contains(deployment.Diff, "-    other-tool/owner: x") {
+		t.Errorf("Deployment diff 未显示将被移除的注解:\n%s", deployment.Diff)
+	}
+	if strings.Contains(deployment.Diff, "replicas") {
+		t.Errorf("HPA 管理的副本数不应出现在差异中:\n%s", deployment.Diff)
+	}
+	if s := byKind["Service"]; s == nil || s.Action != biz.DiffCreate {
+		t.Errorf("Service diff = %+v, want create", s)
+	}
+	if k.get(t, servicesGVR, "default", "demo") != nil {
+		t.Error("对比时创建了 Service")
+	}
+}
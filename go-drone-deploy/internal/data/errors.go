@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os/exec"
+	"syscall"
+
+	"go-drone-deploy/internal/biz"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ClassifyError 识别 Kubernetes API、HTTP、网络错误及外部命令失败的类别
+func (r *deployRepo) ClassifyError(err error) biz.ErrorClass {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return ""
+	case errors.Is(err, context.DeadlineExceeded),
+		apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return biz.ErrorTimeout
+	case apierrors.IsTooManyRequests(err):
+		return biz.ErrorThrottled
+	case apierrors.IsConflict(err):
+		return biz.ErrorConflict
+	case apierrors.IsInternalError(err), apierrors.IsServiceUnavailable(err), apierrors.IsUnexpectedServerError(err):
+		return biz.ErrorServer
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return biz.ErrorThrottled
+		case statusErr.StatusCode >= 500:
+			return biz.ErrorServer
+		}
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return biz.ErrorTimeout
+		}
+		return biz.ErrorNetwork
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return biz.ErrorNetwork
+	}
+
+	// docker 等外部命令只返回退出状态，无法进一步区分原因
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return biz.ErrorExec
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClassifyError(t *testing.T) {
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	tests := []struct {
+		name string
+		err  error
+		want biz.ErrorClass
+	}{
+		{"nil", nil, ""},
+		{"取消", fmt.Errorf("应用失败: %w", context.Canceled), ""},
+		{"超时", fmt.Errorf("应用失败: %w", context.DeadlineExceeded), biz.ErrorTimeout},
+		{"API Server 超时", apierrors.NewServerTimeout(deployments, "update", 1), biz.ErrorTimeout},
+		{"限流", apierrors.NewTooManyRequests("限流", 1), biz.ErrorThrottled},
+		{"冲突", apierrors.NewConflict(deployments, "demo", errors.New("版本过期")), biz.ErrorConflict},
+		{"API Server 不可用", apierrors.NewServiceUnavailable("不可用"), biz.ErrorServer},
+		{"不存在", apierrors.NewNotFound(deployments, "demo"), ""},
+		{"HTTP 429", &httpStatusError{StatusCode: 429}, biz.ErrorThrottled},
+		{"HTTP 502", fmt.Errorf("发送失败: %w", &httpStatusError{StatusCode: 502}), biz.ErrorServer},
+		{"HTTP 400", &httpStatusError{StatusCode: 400}, ""},
+		{"网络超时", &net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}, biz.ErrorTimeout},
+		{"连接被拒绝", fmt.Errorf("连接失败: %w", syscall.ECONNREFUSED), biz.ErrorNetwork},
+		{"docker 退出", fmt.Errorf("推送 Docker 镜像失败: %w", &exec.ExitError{}), biz.ErrorExec},
+		{"其他", errors.New("清单无效"), ""},
+	}
+
+	repo := &deployRepo{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repo.ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultHookTimeout 钩子默认超时时间
+	defaultHookTimeout = 10 * time.Minute
+	// hookTTLSeconds 钩子 Job 完成后保留的时间，便于事后排查
+	hookTTLSeconds int32 = 3600
+	// hookPollInterval 轮询 Job 状态的间隔
+	hookPollInterval = 2 * time.Second
+	// labelHook 标记钩子 Job 的名称
+	labelHook = "go-drone-deploy/hook"
+	// hookDeleteTimeout 删除超时或被取消的钩子 Job 的超时时间
+	hookDeleteTimeout = 30 * time.Second
+	// maxJobNameLength Job 名称的最大长度，Job 名称会写入 Pod 的 job-name 标签
+	maxJobNameLength = 63
+)
+
+// RunK8sHook 以 Job 运行钩子，等待完成并把 Pod 日志输出到部署日志
+func (r *deployRepo) RunK8sHook(ctx context.Context, config *biz.K8sConfig, hook *biz.Hook) error {
+	r.log.WithContext(ctx).Infof("运行 Kubernetes 钩子: %s", hook.Name)
+
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 创建 Job
+	job := r.buildHookJob(config, hook)
+	jobsClient := clientset.BatchV1().Jobs(config.Namespace)
+	job, err = jobsClient.Create(hookCtx, job, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("创建钩子 Job 失败: %w", err)
+	}
+	r.log.WithContext(ctx).Infof("钩子 Job %s 已创建", job.Name)
+
+	// 输出 Pod 日志
+	logsDone := make(chan struct{})
+	var streamed string
+	go func() {
+		defer close(logsDone)
+		streamed = r.streamHookLogs(hookCtx, clientset, config.Namespace, job.Name, hook.Name)
+	}()
+
+	// 等待 Job 完成
+	var failedReason string
+	err = wait.PollUntilContextCancel(hookCtx, hookPollInterval, true, func(ctx context.Context) (bool, error) {
+		current, err := jobsClient.Get(ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		for _, c := range current.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				failedReason = fmt.Sprintf("%s: %s", c.Reason, c.Message)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+
+	if err != nil {
+		// 超时或被取消时删除 Job，避免迁移在后台继续运行；部署被取消时仍需删除，但不无限等待
+		deleteCtx, cancelDelete := context.WithTimeout(context.WithoutCancel(ctx), hookDeleteTimeout)
+		defer cancelDelete()
+		propagation := metav1.DeletePropagationBackground
+		if err := jobsClient.Delete(deleteCtx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			r.log.WithContext(ctx).Warnf("删除钩子 Job %s 失败: %v", job.Name, err)
+		}
+		if ctx.Err() == nil {
+			return fmt.Errorf("钩子 Job %s 在 %s 内未完成", job.Name, timeout)
+		}
+		return fmt.Errorf("等待钩子 Job %s 被取消: %w", job.Name, ctx.Err())
+	}
+
+	// 等待日志输出结束
+	streamDone := false
+	select {
+	case <-logsDone:
+		streamDone = true
+	case <-time.After(5 * time.Second):
+	}
+
+	if failedReason != "" {
+		// Job 重试过时失败的 Pod 可能不是跟随日志的 Pod，输出最新失败 Pod 的日志
+		if pod := r.newestFailedHookPod(hookCtx, clientset, config.Namespace, job.Name); pod != "" {
+			if !streamDone || pod != streamed {
+				r.hookPodLogs(hookCtx, clientset, config.Namespace, pod, hook.Name, int64(config.DiagnosticLogLines))
+			}
+			return fmt.Errorf("钩子 Job %s 失败（Pod %s）: %s", job.Name, pod, failedReason)
+		}
+		return fmt.Errorf("钩子 Job %s 失败: %s", job.Name, failedReason)
+	}
+
+	r.log.WithContext(ctx).Infof("钩子 %s 运行成功", hook.Name)
+	return nil
+}
+
+// streamHookLogs 跟随最新启动的钩子 Pod 的日志并逐行写入部署日志，返回跟随的 Pod 名称
+func (r *deployRepo) streamHookLogs(ctx context.Context, clientset kubernetes.Interface, namespace, jobName, hookName string) string {
+	podsClient := clientset.CoreV1().Pods(namespace)
+
+	// 等待 Pod 启动
+	var podName string
+	_ = wait.PollUntilContextCancel(ctx, hookPollInterval, true, func(ctx context.Context) (bool, error) {
+		pods, err := podsClient.List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + jobName})
+		if err != nil {
+			return false, nil
+		}
+		pod := newestPod(pods.Items, func(pod *corev1.Pod) bool {
+			return pod.Status.Phase != corev1.PodPending
+		})
+		if pod == nil {
+			return false, nil
+		}
+		podName = pod.Name
+		return true, nil
+	})
+	if podName == "" {
+		return ""
+	}
+
+	stream, err := podsClient.GetLogs(podName, &corev1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		r.log.WithContext(ctx).Warnf("获取钩子 %s 日志失败: %v", hookName, err)
+		return podName
+	}
+	defer stream.Close()
+
+	r.writeHookLogs(ctx, stream, hookName)
+	return podName
+}
+
+// newestFailedHookPod 返回钩子 Job 最新失败的 Pod 名称，没有时为空
+func (r *deployRepo) newestFailedHookPod(ctx context.Context, clientset kubernetes.Interface, namespace, jobName string) string {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + jobName})
+	if err != nil {
+		r.log.WithContext(ctx).Warnf("获取钩子 Job %s 的 Pod 失败: %v", jobName, err)
+		return ""
+	}
+	pod := newestPod(pods.Items, func(pod *corev1.Pod) bool {
+		return pod.Status.Phase == corev1.PodFailed
+	})
+	if pod == nil {
+		return ""
+	}
+	return pod.Name
+}
+
+// hookPodLogs 将钩子 Pod 的最后若干行日志写入部署日志
+func (r *deployRepo) hookPodLogs(ctx context.Context, clientset kubernetes.Interface, namespace, podName, hookName string, tailLines int64) {
+	if tailLines <= 0 {
+		tailLines = defaultDiagnosticLogLines
+	}
+	stream, err := clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{TailLines: &tailLines}).Stream(ctx)
+	if err != nil {
+		r.log.WithContext(ctx).Warnf("获取钩子 Pod %s 日志失败: %v", podName, err)
+		return
+	}
+	defer stream.Close()
+
+	r.log.WithContext(ctx).Infof("[hook %s] 失败的 Pod %s 最后 %d 行日志:", hookName, podName, tailLines)
+	r.writeHookLogs(ctx, stream, hookName)
+}
+
+// writeHookLogs 逐行写入钩子日志
+func (r *deployRepo) writeHookLogs(ctx context.Context, stream io.Reader, hookName string) {
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		r.log.WithContext(ctx).Infof("[hook %s] %s", hookName, strings.TrimRight(scanner.Text(), "\r"))
+	}
+}
+
+// newestPod 返回满足条件的最新创建的 Pod，没有时为 nil
+func newestPod(pods []corev1.Pod, match func(pod *corev1.Pod) bool) *corev1.Pod {
+	var newest *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if !match(pod) {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&pod.CreationTimestamp) ||
+			(newest.CreationTimestamp.Equal(&pod.CreationTimestamp) && pod.Name > newest.Name) {
+			newest = pod
+		}
+	}
+	return newest
+}
+
+// hookJobName 生成钩子 Job 名称：部署名称-钩子名称-时间戳，超过 63 个字符时截断并附加摘要以保持唯一
+func hookJobName(deploymentName, hookName string, now time.Time) string {
+	base := deploymentName + "-" + hookName
+	suffix := "-" + strconv.FormatInt(now.Unix(), 10)
+	if len(base)+len(suffix) <= maxJobNameLength {
+		return base + suffix
+	}
+
+	sum := sha256.Sum256([]byte(base))
+	hash := hex.EncodeToString(sum[:4])
+	prefix := strings.TrimRight(base[:maxJobNameLength-len(suffix)-len(hash)-1], "-.")
+	return prefix + "-" + hash + suffix
+}
+
+// buildHookJob 构建钩子 Job，使用主容器镜像及 Pod 的身份、调度与安全配置
+func (r *deployRepo) buildHookJob(config *biz.K8sConfig, hook *biz.Hook) *batchv1.Job {
+	labels := ownershipLabels(config)
+	labels[labelHook] = hook.Name
+
+	backoffLimit := hook.BackoffLimit
+	ttl := hookTTLSeconds
+	name := hookJobName(config.DeploymentName, hook.Name, time.Now())
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: config.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: config.ServiceAccountName,
+					ImagePullSecrets:   buildImagePullSecrets(config.ImagePullSecrets),
+					NodeSelector:       config.NodeSelector,
+					Tolerations:        buildTolerations(config.Tolerations),
+					SecurityContext:    buildPodSecurityContext(config.SecurityContext),
+					Containers: []corev1.Container{
+						{
+							Name:            hook.Name,
+							Image:           config.Image,
+							Command:         hook.Command,
+							Args:            hook.Args,
+							Env:             append(buildEnvVars(config.EnvVars), buildEnvVars(hook.EnvVars)...),
+							SecurityContext: buildContainerSecurityContext(config.SecurityContext),
+						},
+					},
+				},
+			},
+		},
+	}
+}
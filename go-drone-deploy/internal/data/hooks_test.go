@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestHookJobName(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	if got := hookJobName("demo", "migrate", now); got != "demo-migrate-1700000000" {
+		t.Errorf("hookJobName() = %s", got)
+	}
+
+	long := strings.Repeat("a", 40)
+	name := hookJobName(long, "migrate-database-schema", now)
+	if len(name) > maxJobNameLength {
+		t.Errorf("hookJobName() 长度 = %d, want <= %d", len(name), maxJobNameLength)
+	}
+	if !strings.HasSuffix(name, "-1700000000") {
+		t.Errorf("hookJobName() = %s, 缺少时间戳", name)
+	}
+	if other := hookJobName(long, "migrate-database-seeds", now); other == name {
+		t.Errorf("截断后的名称冲突: %s", name)
+	}
+}
+
+func TestNewestPod(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	pod := func(name string, phase corev1.PodPhase, age time.Duration) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(base.Add(-age))},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	pods := []corev1.Pod{
+		pod("first", corev1.PodFailed, 2*time.Minute),
+		pod("retry", corev1.PodFailed, time.Minute),
+		pod("pending", corev1.PodPending, 0),
+	}
+	failed := func(pod *corev1.Pod) bool { return pod.Status.Phase == corev1.PodFailed }
+
+	if got := newestPod(pods, failed); got == nil || got.Name != "retry" {
+		t.Errorf("newestPod() = %v, want retry", got)
+	}
+	if got := newestPod(pods[2:], failed); got != nil {
+		t.Errorf("newestPod() = %s, want nil", got.Name)
+	}
+}
+
+// TestRunK8sHookFailed Job 重试后失败时错误指向最新失败的 Pod
+func TestRunK8sHookFailed(t *testing.T) {
+	repo, k := newTestRepo(t, nil)
+	k.clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "重试次数已用尽",
+		}}
+		// 模拟 Job 控制器创建的两次尝试
+		for i, name := range []string{"first", "retry"} {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              job.Name + "-" + name,
+					Namespace:         job.Namespace,
+					Labels:            map[string]string{"job-name": job.Name},
+					CreationTimestamp: metav1.NewTime(time.Now().Add(time.Duration(i) * time.Minute)),
+				},
+				Status: corev1.PodStatus{Phase: corev1.PodFailed},
+			}
+			if err := k.clientset.Tracker().Add(pod); err != nil {
+				return true, nil, err
+			}
+		}
+		return false, nil, nil
+	})
+
+	config := rolloutTestConfig()
+	err := repo.RunK8sHook(context.Background(), config, &biz.Hook{Name: "migrate", Command: []string{"migrate"}})
+	if err == nil || !strings.Contains(err.Error(), "-retry") || !strings.Contains(err.Error(), "BackoffLimitExceeded") {
+		t.Fatalf("RunK8sHook() error = %v, want 最新失败的 Pod", err)
+	}
+}
+
+// TestRunK8sHookCleanup 超时或部署被取消时删除 Job
+func TestRunK8sHookCleanup(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		wantErr string
+	}{
+		{name: "超时", timeout: 50 * time.Millisecond, wantErr: "未完成"},
+		{name: "取消", timeout: time.Minute, cancel: true, wantErr: "被取消"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, k := newTestRepo(t, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+
+			err := repo.RunK8sHook(ctx, rolloutTestConfig(), &biz.Hook{Name: "migrate", Timeout: tt.timeout})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("RunK8sHook() error = %v, want %s", err, tt.wantErr)
+			}
+			jobs, err := k.clientset.BatchV1().Jobs("default").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(jobs.Items) != 0 {
+				t.Errorf("钩子 Job 未被删除: %s", jobs.Items[0].Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// defaultScanSeverity 默认导致扫描失败的漏洞等级
+const defaultScanSeverity = "HIGH,CRITICAL"
+
+// ScanDockerImage 使用 trivy 扫描本地镜像，存在指定等级的漏洞时失败
+func (r *deployRepo) ScanDockerImage(ctx context.Context, config *biz.DockerConfig) error {
+	r.log.WithContext(ctx).Infof("扫描 Docker 镜像: %s", config.ImageName)
+
+	severity := defaultScanSeverity
+	ignoreUnfixed := false
+	if config.Scan != nil {
+		if config.Scan.Severity != "" {
+			severity = config.Scan.Severity
+		}
+		ignoreUnfixed = config.Scan.IgnoreUnfixed
+	}
+
+	args := []string{"image", "--exit-code", "1", "--severity", severity}
+	if ignoreUnfixed {
+		args = append(args, "--ignore-unfixed")
+	}
+	args = append(args, config.ImageName)
+
+	cmd := exec.CommandContext(ctx, "trivy", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("镜像扫描未通过: %w", err)
+	}
+
+	r.log.WithContext(ctx).Info("Docker 镜像扫描通过")
+	return nil
+}
+
+// SignDockerImage 使用 cosign 签名已推送的镜像，优先按摘要签名
+func (r *deployRepo) SignDockerImage(ctx context.Context, config *biz.DockerConfig) error {
+	image := config.Digest
+	if image == "" {
+		r.log.WithContext(ctx).Warnf("镜像摘要为空，按标签签名: %s", config.ImageName)
+		image = config.ImageName
+	}
+	r.log.WithContext(ctx).Infof("签名 Docker 镜像: %s", image)
+
+	args := []string{"sign", "--yes"}
+	if config.Sign != nil && config.Sign.Key != "" {
+		args = append(args, "--key", config.Sign.Key)
+	}
+	args = append(args, image)
+
+	cmd := exec.CommandContext(ctx, "cosign", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("镜像签名失败: %w", err)
+	}
+
+	r.log.WithContext(ctx).Info("Docker 镜像签名成功")
+	return nil
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	// defaultK8sQPS 客户端默认每秒请求数
+	defaultK8sQPS float32 = 50
+	// defaultK8sBurst 客户端默认突发请求数
+	defaultK8sBurst = 100
+	// defaultK8sRequestTimeout 单次请求的默认超时时间
+	defaultK8sRequestTimeout = 30 * time.Second
+)
+
+// k8sClients 同一集群共享的客户端
+type k8sClients struct {
+	clientset  kubernetes.Interface
+	dynamic    dynamic.Interface
+	mapper     meta.ResettableRESTMapper
+	httpClient *http.Client
+}
+
+// k8sClientKey 集群连接配置，相同配置复用同一组客户端
+type k8sClientKey struct {
+	kubeconfigPath        string
+	kubeconfig            string
+	context               string
+	inCluster             bool
+	server                string
+	token                 string
+	tokenFile             string
+	caFile                string
+	caData                string
+	insecureSkipTLSVerify bool
+	qps                   float32
+	burst                 int
+	requestTimeout        time.Duration
+}
+
+// newK8sClientKey 根据配置生成缓存键
+func newK8sClientKey(config *biz.K8sConfig) k8sClientKey {
+	return k8sClientKey{
+		kubeconfigPath:        config.KubeconfigPath,
+		kubeconfig:            config.Kubeconfig,
+		context:               config.Context,
+		inCluster:             config.InCluster,
+		server:                config.Server,
+		token:                 config.Token,
+		tokenFile:             config.TokenFile,
+		caFile:                config.CAFile,
+		caData:                config.CAData,
+		insecureSkipTLSVerify: config.InsecureSkipTLSVerify,
+		qps:                   config.QPS,
+		burst:                 config.Burst,
+		requestTimeout:        config.RequestTimeout,
+	}
+}
+
+// k8sClients 获取集群客户端，首次使用时创建并缓存
+func (d *Data) k8sClients(config *biz.K8sConfig) (*k8sClients, error) {
+	if d.k8sOverride != nil {
+		return d.k8sOverride, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := newK8sClientKey(config)
+	if clients, ok := d.k8s[key]; ok {
+		return clients, nil
+	}
+
+	clients, err := newK8sClients(config)
+	if err != nil {
+		return nil, err
+	}
+	d.k8s[key] = clients
+	return clients, nil
+}
+
+// closeK8sClients 关闭所有缓存客户端的空闲连接
+func (d *Data) closeK8sClients() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, clients := range d.k8s {
+		clients.httpClient.CloseIdleConnections()
+		delete(d.k8s, key)
+	}
+}
+
+// newK8sClients 创建共享同一 HTTP 连接的类型化客户端、动态客户端及 REST 映射
+func newK8sClients(config *biz.K8sConfig) (*k8sClients, error) {
+	restConfig, err := loadRestConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig.QPS = config.QPS
+	if restConfig.QPS <= 0 {
+		restConfig.QPS = defaultK8sQPS
+	}
+	restConfig.Burst = config.Burst
+	if restConfig.Burst <= 0 {
+		restConfig.Burst = defaultK8sBurst
+	}
+	timeout := config.RequestTimeout
+	if timeout <= 0 {
+		timeout = defaultK8sRequestTimeout
+	}
+	restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &timeoutRoundTripper{next: rt, timeout: timeout}
+	})
+
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes HTTP 客户端失败: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 动态客户端失败: %w", err)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 发现客户端失败: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	return &k8sClients{
+		clientset:  clientset,
+		dynamic:    dynamicClient,
+		mapper:     mapper,
+		httpClient: httpClient,
+	}, nil
+}
+
+// timeoutRoundTripper 为普通请求设置超时，watch 和日志跟随等长连接请求不受影响
+type timeoutRoundTripper struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	if query.Get("watch") == "true" || query.Get("follow") == "true" {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// 响应体读取完毕后再释放超时上下文
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose 关闭响应体时取消请求上下文
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
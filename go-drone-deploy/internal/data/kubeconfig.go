@@ -0,0 +1,146 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// loadRestConfig 按配置加载集群连接信息，优先级：
+// InCluster > 内联 kubeconfig > kubeconfig_path > KUBECONFIG > ~/.kube/config > 集群内 ServiceAccount
+func loadRestConfig(config *biz.K8sConfig) (*rest.Config, error) {
+	if config.InCluster {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("加载集群内认证信息失败: %w", err)
+		}
+		return restConfig, applyAuthOverrides(restConfig, config)
+	}
+
+	overrides, err := buildConfigOverrides(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientConfig clientcmd.ClientConfig
+	switch {
+	case config.Kubeconfig != "":
+		// 使用内联的 kubeconfig 内容
+		apiConfig, err := clientcmd.Load([]byte(config.Kubeconfig))
+		if err != nil {
+			return nil, fmt.Errorf("解析 kubeconfig 内容失败: %w", err)
+		}
+		clientConfig = clientcmd.NewNonInteractiveClientConfig(*apiConfig, config.Context, overrides, nil)
+	case config.KubeconfigPath == "" && config.Server != "":
+		// 仅通过集群地址与令牌连接，不读取 kubeconfig 文件
+		clientConfig = clientcmd.NewNonInteractiveClientConfig(*clientcmdapi.NewConfig(), "", overrides, nil)
+	default:
+		// 默认规则读取 KUBECONFIG 环境变量及 ~/.kube/config，找不到时回退到集群内认证
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		if config.KubeconfigPath != "" {
+			path, err := expandHome(config.KubeconfigPath)
+			if err != nil {
+				return nil, err
+			}
+			rules.ExplicitPath = path
+		}
+		clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("加载 kubeconfig 失败: %w", err)
+	}
+
+	return restConfig, nil
+}
+
+// buildConfigOverrides 根据配置生成上下文、集群地址与认证信息的覆盖项
+func buildConfigOverrides(config *biz.K8sConfig) (*clientcmd.ConfigOverrides, error) {
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: config.Context,
+		ClusterInfo: clientcmdapi.Cluster{
+			Server:                config.Server,
+			InsecureSkipTLSVerify: config.InsecureSkipTLSVerify,
+		},
+		AuthInfo: clientcmdapi.AuthInfo{
+			Token: config.Token,
+		},
+	}
+
+	if config.TokenFile != "" {
+		path, err := expandHome(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		overrides.AuthInfo.TokenFile = path
+	}
+	if config.CAFile != "" {
+		path, err := expandHome(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		overrides.ClusterInfo.CertificateAuthority = path
+	}
+	if config.CAData != "" {
+		overrides.ClusterInfo.CertificateAuthorityData = []byte(config.CAData)
+	}
+
+	return overrides, nil
+}
+
+// applyAuthOverrides 将配置中的令牌与 CA 应用到已加载的连接信息上
+func applyAuthOverrides(restConfig *rest.Config, config *biz.K8sConfig) error {
+	if config.Server != "" {
+		restConfig.Host = config.Server
+	}
+	if config.Token != "" {
+		restConfig.BearerToken = config.Token
+		restConfig.BearerTokenFile = ""
+	}
+	if config.TokenFile != "" {
+		path, err := expandHome(config.TokenFile)
+		if err != nil {
+			return err
+		}
+		restConfig.BearerToken = ""
+		restConfig.BearerTokenFile = path
+	}
+	if config.CAFile != "" {
+		path, err := expandHome(config.CAFile)
+		if err != nil {
+			return err
+		}
+		restConfig.TLSClientConfig.CAFile = path
+		restConfig.TLSClientConfig.CAData = nil
+	}
+	if config.CAData != "" {
+		restConfig.TLSClientConfig.CAData = []byte(config.CAData)
+		restConfig.TLSClientConfig.CAFile = ""
+	}
+	if config.InsecureSkipTLSVerify {
+		restConfig.TLSClientConfig.Insecure = true
+		restConfig.TLSClientConfig.CAFile = ""
+		restConfig.TLSClientConfig.CAData = nil
+	}
+	return nil
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户主目录失败: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// labelManagedBy 标记对象由本工具管理
+	labelManagedBy = "app.kubernetes.io/managed-by"
+	// labelInstance 标记对象所属的发布实例
+	labelInstance = "app.kubernetes.io/instance"
+	// labelName 标记应用名称
+	labelName = "app.kubernetes.io/name"
+	// labelVersion 标记应用版本
+	labelVersion = "app.kubernetes.io/version"
+	// labelComponent 标记应用在架构中的组件
+	labelComponent = "app.kubernetes.io/component"
+	// labelPartOf 标记应用所属的上层系统
+	labelPartOf = "app.kubernetes.io/part-of"
+	// managedByValue managed-by 标签的取值
+	managedByValue = "go-drone-deploy"
+	// pruneProtectionAnnotation 设置为 "true" 的对象不会被清理
+	pruneProtectionAnnotation = "go-drone-deploy/prune-protection"
+
+	// 部署元数据注解
+	annotationCommit     = "go-drone-deploy/commit"
+	annotationBuildLink  = "go-drone-deploy/build-link"
+	annotationAuthor     = "go-drone-deploy/author"
+	annotationDeployedAt = "go-drone-deploy/deployed-at"
+)
+
+// deployAnnotations 每次部署都会变化的注解，对比差异时忽略
+var deployAnnotations = []string{annotationCommit, annotationBuildLink, annotationAuthor, annotationDeployedAt}
+
+// releaseName 返回发布实例名称，未配置时使用 Deployment 名称
+func releaseName(config *biz.K8sConfig) string {
+	if config.Release != "" {
+		return config.Release
+	}
+	return config.DeploymentName
+}
+
+// selectorLabels 返回 Pod 选择器标签，创建后不可变更
+func selectorLabels(config *biz.K8sConfig) map[string]string {
+	return map[string]string{
+		"app": config.DeploymentName,
+	}
+}
+
+// objectLabels 返回生成对象及 Pod 的标签：自定义标签、推荐标签、选择器标签，后者优先
+func objectLabels(config *biz.K8sConfig) map[string]string {
+	labels := map[string]string{}
+	for key, value := range config.Labels {
+		labels[key] = value
+	}
+	for key, value := range recommendedLabels(config) {
+		labels[key] = value
+	}
+	for key, value := range selectorLabels(config) {
+		labels[key] = value
+	}
+	return labels
+}
+
+// recommendedLabels 返回 app.kubernetes.io 推荐标签，跳过为空或不是合法标签值的项
+func recommendedLabels(config *biz.K8sConfig) map[string]string {
+	labels := ownershipLabels(config)
+	for key, value := range map[string]string{
+		labelName:      config.AppName,
+		labelVersion:   config.Version,
+		labelComponent: config.Component,
+		labelPartOf:    config.PartOf,
+	} {
+		if value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// ownershipLabels 返回归属标签，用于识别和清理本工具写入的对象
+func ownershipLabels(config *biz.K8sConfig) map[string]string {
+	return map[string]string{
+		labelManagedBy: managedByValue,
+		labelInstance:  releaseName(config),
+	}
+}
+
+// versionLabels 返回版本标签，版本不是合法的标签值时为空
+func versionLabels(config *biz.K8sConfig) map[string]string {
+	labels := map[string]string{}
+	if config.Version != "" && len(validation.IsValidLabelValue(config.Version)) == 0 {
+		labels[labelVersion] = config.Version
+	}
+	return labels
+}
+
+// objectAnnotations 返回生成对象的注解：自定义注解加部署元数据
+func objectAnnotations(config *biz.K8sConfig) map[string]string {
+	annotations := map[string]string{}
+	for key, value := range config.Annotations {
+		annotations[key] = value
+	}
+	if meta := config.DeployMeta; meta != nil {
+		for key, value := range map[string]string{
+			annotationCommit:    meta.Commit,
+			annotationBuildLink: meta.BuildLink,
+			annotationAuthor:    meta.Author,
+		} {
+			if value != "" {
+				annotations[key] = value
+			}
+		}
+		if !meta.Time.IsZero() {
+			annotations[annotationDeployedAt] = meta.Time.UTC().Format(time.RFC3339)
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
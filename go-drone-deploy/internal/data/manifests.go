@@ -0,0 +1,361 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// crdEstablishTimeout 等待 CRD 生效的超时时间
+	crdEstablishTimeout = 60 * time.Second
+	// templateExt 按 Go 模板渲染的清单文件后缀
+	templateExt = ".tmpl"
+)
+
+// kindOrder 应用顺序，未列出的类型排在最后
+var kindOrder = map[string]int{
+	"Namespace":                0,
+	"CustomResourceDefinition": 1,
+	"PriorityClass":            2,
+	"StorageClass":             2,
+	"ClusterRole":              2,
+	"ClusterRoleBinding":       3,
+	"ServiceAccount":           3,
+	"Role":                     3,
+	"RoleBinding":              4,
+	"Secret":                   4,
+	"ConfigMap":                4,
+	"PersistentVolumeClaim":    4,
+	"ResourceQuota":            4,
+	"LimitRange":               4,
+	"NetworkPolicy":            5,
+}
+
+// ApplyK8sManifests 以服务端 apply 按依赖顺序应用额外清单
+func (r *deployRepo) ApplyK8sManifests(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Info("应用额外 Kubernetes 清单")
+
+	// 创建 Kubernetes 动态客户端
+	dynamicClient, mapper, err := r.createDynamicClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	objects, err := r.loadManifests(ctx, config)
+	if err != nil {
+		return err
+	}
+	sortObjects(objects)
+
+	crdsApplied := false
+	for _, obj := range objects {
+		// 应用 CRD 之后的第一个普通对象前，等待 CRD 生效并刷新类型映射
+		if crdsApplied && !isCRD(obj) {
+			if err := r.waitForCRDs(ctx, dynamicClient, mapper, objects); err != nil {
+				return err
+			}
+			crdsApplied = false
+		}
+
+		if err := applyObject(ctx, dynamicClient, mapper, obj); err != nil {
+			return fmt.Errorf("应用 %s 失败: %w", objectID(obj), err)
+		}
+		r.log.WithContext(ctx).Infof("%s 应用成功", objectID(obj))
+
+		if isCRD(obj) {
+			crdsApplied = true
+		}
+	}
+
+	return nil
+}
+
+// applyObject 以服务端 apply 应用单个对象
+func applyObject(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) error {
+	ri, err := resourceInterface(client, mapper, obj)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("序列化对象失败: %w", err)
+	}
+	force := true
+	_, err = ri.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: fieldManager,
+		Force:        &force,
+	})
+	return err
+}
+
+// waitForCRDs 等待已应用的 CRD 进入 Established 状态，并刷新类型映射
+func (r *deployRepo) waitForCRDs(ctx context.Context, client dynamic.Interface, mapper meta.ResettableRESTMapper, objects []*unstructured.Unstructured) error {
+	for _, obj := range objects {
+		if !isCRD(obj) {
+			continue
+		}
+
+		ri, err := resourceInterface(client, mapper, obj)
+		if err != nil {
+			return err
+		}
+		err = wait.PollUntilContextTimeout(ctx, time.Second, crdEstablishTimeout, true, func(ctx context.Context) (bool, error) {
+			crd, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return false, nil
+			}
+			conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+			for _, c := range conditions {
+				condition, ok := c.(map[string]interface{})
+				if ok && condition["type"] == "Established" && condition["status"] == "True" {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("等待 CRD %s 生效超时: %w", obj.GetName(), err)
+		}
+	}
+
+	mapper.Reset()
+	return nil
+}
+
+// loadManifests 读取、解析额外清单，模板文件先按部署变量渲染；未指定命名空间的对象使用部署命名空间
+func (r *deployRepo) loadManifests(ctx context.Context, config *biz.K8sConfig) ([]*unstructured.Unstructured, error) {
+	m := config.Manifests
+	if m == nil {
+		return nil, nil
+	}
+
+	var sources []manifestSource
+	for _, path := range m.Paths {
+		files, err := manifestFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("读取清单 %s 失败: %w", file, err)
+			}
+			sources = append(sources, manifestSource{
+				name:     file,
+				content:  content,
+				template: m.Template || isTemplateFile(file),
+			})
+		}
+	}
+
+	// kustomize 的输出可能包含 {{ }}（如 ConfigMap 中的配置模板），不按模板渲染
+	if m.Kustomization != "" {
+		content, err := kustomizeBuild(ctx, m.Kustomization)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, manifestSource{name: m.Kustomization, content: content})
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, src := range sources {
+		content := src.content
+		if src.template {
+			rendered, err := renderTemplate(src.name, content, m.Vars)
+			if err != nil {
+				return nil, err
+			}
+			content = rendered
+		}
+		decoded, err := decodeManifests(content)
+		if err != nil {
+			return nil, fmt.Errorf("解析清单 %s 失败: %w", src.name, err)
+		}
+		objects = append(objects, decoded...)
+	}
+
+	for _, obj := range objects {
+		if obj.GetNamespace() == "" && !isClusterScoped(obj) {
+			obj.SetNamespace(config.Namespace)
+		}
+
+		// 添加归属标签
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		for key, value := range ownershipLabels(config) {
+			labels[key] = value
+		}
+		obj.SetLabels(labels)
+	}
+
+	return objects, nil
+}
+
+// manifestSource 清单来源
+type manifestSource struct {
+	name    string
+	content []byte
+	// template 是否按 Go 模板渲染
+	template bool
+}
+
+// isTemplateFile 判断清单文件是否为模板
+func isTemplateFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), templateExt)
+}
+
+// manifestFiles 展开文件或目录为清单文件列表
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取清单路径 %s 失败: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// 模板文件按去掉 .tmpl 后的扩展名判断
+		ext := filepath.Ext(p)
+		if strings.EqualFold(ext, templateExt) {
+			ext = filepath.Ext(strings.TrimSuffix(p, ext))
+		}
+		switch strings.ToLower(ext) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				files = append(files, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历清单目录 %s 失败: %w", path, err)
+	}
+	return files, nil
+}
+
+// kustomizeBuild 通过 kubectl kustomize 构建清单
+func kustomizeBuild(ctx context.Context, dir string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", "kustomize", dir)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("kustomize 构建 %s 失败: %w: %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// renderTemplate 使用部署变量渲染清单模板
+func renderTemplate(name string, content []byte, vars map[string]string) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("解析清单模板 %s 失败: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("渲染清单模板 %s 失败: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeManifests 解析多文档 YAML/JSON，展开 List 类型
+func decodeManifests(content []byte) ([]*unstructured.Unstructured, error) {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+
+	var objects []*unstructured.Unstructured
+	for {
+		var raw map[string]interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("对象缺少 apiVersion 或 kind: %v", obj.GetName())
+		}
+
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+// sortObjects 按依赖顺序稳定排序：Namespace、CRD 在前
+func sortObjects(objects []*unstructured.Unstructured) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return kindRank(objects[i]) < kindRank(objects[j])
+	})
+}
+
+// kindRank 返回对象类型的应用顺序
+func kindRank(obj *unstructured.Unstructured) int {
+	if rank, ok := kindOrder[obj.GetKind()]; ok {
+		return rank
+	}
+	return len(kindOrder)
+}
+
+// isCRD 判断对象是否为 CRD
+func isCRD(obj *unstructured.Unstructured) bool {
+	return obj.GetKind() == "CustomResourceDefinition"
+}
+
+// isClusterScoped 判断常见的集群级对象，用于补全命名空间
+func isClusterScoped(obj *unstructured.Unstructured) bool {
+	switch obj.GetKind() {
+	case "Namespace", "CustomResourceDefinition", "ClusterRole", "ClusterRoleBinding",
+		"PriorityClass", "StorageClass", "PersistentVolume", "IngressClass",
+		"ValidatingWebhookConfiguration", "MutatingWebhookConfiguration":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const templateManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+data:
+  value: "{{ .Env }}"
+`
+
+// TestLoadManifestsTemplate 只有 .tmpl 文件或设置了 Template 时按模板渲染
+func TestLoadManifestsTemplate(t *testing.T) {
+	dir := t.TempDir()
+	for file, name := range map[string]string{
+		"plain.yaml":          "plain",
+		"templated.yaml.tmpl": "templated",
+		"ignored.txt":         "ignored",
+	} {
+		content := []byte(fmt.Sprintf(templateManifest, name))
+		if err := os.WriteFile(filepath.Join(dir, file), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		template bool
+		want     map[string]string
+	}{
+		{"默认", false, map[string]string{"plain": "{{ .Env }}", "templated": "prod"}},
+		{"全部渲染", true, map[string]string{"plain": "prod", "templated": "prod"}},
+	}
+	repo, _ := newTestRepo(t, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &biz.K8sConfig{
+				Namespace:      "default",
+				DeploymentName: "demo",
+				Manifests:      &biz.Manifests{Paths: []string{dir}, Template: tt.template, Vars: map[string]string{"Env": "prod"}},
+			}
+			objects, err := repo.loadManifests(context.Background(), config)
+			if err != nil {
+				t.Fatalf("loadManifests() error = %v", err)
+			}
+
+			got := map[string]string{}
+			for _, obj := range objects {
+				got[obj.GetName()], _, _ = unstructured.NestedString(obj.Object, "data", "value")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("objects = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if got[name] != value {
+					t.Errorf("%s value = %q, want %q", name, got[name], value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"go-drone-deploy/internal/biz"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// bootstrapObjectName 命名空间级 ResourceQuota、LimitRange 的名称
+	bootstrapObjectName = "go-drone-deploy"
+	// dockerHubServer Docker Hub 在 dockerconfigjson 中的服务器地址
+	dockerHubServer = "https://index.docker.io/v1/"
+)
+
+// BootstrapK8sNamespace 初始化命名空间：命名空间、资源配额、默认资源限制不存在时创建，已存在的保持不变，
+// 设置了 Manage 时以服务端 apply 更新；镜像拉取 Secret 随 Docker 仓库凭证始终更新
+func (r *deployRepo) BootstrapK8sNamespace(ctx context.Context, config *biz.K8sConfig, docker *biz.DockerConfig) error {
+	r.log.WithContext(ctx).Infof("初始化 Kubernetes 命名空间: %s", config.Namespace)
+
+	// 创建 Kubernetes 动态客户端
+	dynamicClient, mapper, err := r.createDynamicClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	objects, err := r.buildBootstrapObjects(config)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if config.Bootstrap.Manage {
+			if err := applyObject(ctx, dynamicClient, mapper, obj); err != nil {
+				return fmt.Errorf("应用 %s 失败: %w", objectID(obj), err)
+			}
+			r.log.WithContext(ctx).Infof("%s 应用成功", objectID(obj))
+			continue
+		}
+
+		created, err := createIfMissing(ctx, dynamicClient, mapper, obj)
+		if err != nil {
+			return fmt.Errorf("创建 %s 失败: %w", objectID(obj), err)
+		}
+		if created {
+			r.log.WithContext(ctx).Infof("%s 创建成功", objectID(obj))
+		} else {
+			r.log.WithContext(ctx).Infof("%s 已存在，保持不变", objectID(obj))
+		}
+	}
+
+	secret, err := r.buildBootstrapSecret(config, docker)
+	if err != nil || secret == nil {
+		return err
+	}
+	if err := applyObject(ctx, dynamicClient, mapper, secret); err != nil {
+		return fmt.Errorf("应用 %s 失败: %w", objectID(secret), err)
+	}
+	r.log.WithContext(ctx).Infof("%s 应用成功", objectID(secret))
+
+	return nil
+}
+
+// createIfMissing 对象不存在时创建，已存在时不做任何修改，返回是否创建
+func createIfMissing(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) (bool, error) {
+	ri, err := resourceInterface(client, mapper, obj)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	_, err = ri.Create(ctx, obj, metav1.CreateOptions{FieldManager: fieldManager})
+	if apierrors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// diffBootstrap 对比命名空间初始化的对象，与 BootstrapK8sNamespace 的行为一致：
+// 未接管时已存在的对象保持不变，只有不存在的对象会被创建
+func (r *deployRepo) diffBootstrap(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	config *biz.K8sConfig, docker *biz.DockerConfig) ([]*biz.ResourceDiff, error) {
+	objects, err := r.buildBootstrapObjects(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []*biz.ResourceDiff
+	for _, obj := range objects {
+		var d *biz.ResourceDiff
+		if config.Bootstrap.Manage {
+			d, err = r.diffObject(ctx, client, mapper, obj)
+		} else {
+			d, err = diffMissing(ctx, client, mapper, obj)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("对比 %s 失败: %w", objectID(obj), err)
+		}
+		diffs = append(diffs, d)
+	}
+
+	secret, err := r.buildBootstrapSecret(config, docker)
+	if err != nil {
+		return nil, err
+	}
+	if secret != nil {
+		d, err := r.diffObject(ctx, client, mapper, secret)
+		if err != nil {
+			return nil, fmt.Errorf("对比 %s 失败: %w", objectID(secret), err)
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// diffMissing 对比只在不存在时创建的对象：不存在时为创建，已存在时无变更
+func diffMissing(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) (*biz.ResourceDiff, error) {
+	ri, err := resourceInterface(client, mapper, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &biz.ResourceDiff{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName(), Action: biz.DiffUnchanged}
+	_, err = ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	switch {
+	case err == nil:
+		return d, nil
+	case !apierrors.IsNotFound(err):
+		return nil, fmt.Errorf("获取现有对象失败: %w", err)
+	}
+
+	d.Action = biz.DiffCreate
+	if d.Diff, err = unifiedDiff(nil, normalizeObject(obj), objectID(obj)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// buildBootstrapObjects 构建命名空间、资源配额及默认资源限制，Namespace 在最前；不包含镜像拉取 Secret
+func (r *deployRepo) buildBootstrapObjects(config *biz.K8sConfig) ([]*unstructured.Unstructured, error) {
+	b := config.Bootstrap
+	labels := map[string]string{}
+	for key, value := range b.Labels {
+		labels[key] = value
+	}
+	if b.PodSecurity != "" {
+		for _, mode := range []string{"enforce", "audit", "warn"} {
+			labels["pod-security.kubernetes.io/"+mode] = b.PodSecurity
+		}
+	}
+
+	objects := []runtime.Object{
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        config.Namespace,
+				Labels:      labels,
+				Annotations: b.Annotations,
+			},
+		},
+	}
+
+	if len(b.ResourceQuota) > 0 {
+		hard, err := buildResourceList(b.ResourceQuota)
+		if err != nil {
+			return nil, fmt.Errorf("解析资源配额失败: %w", err)
+		}
+		objects = append(objects, &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      bootstrapObjectName,
+				Namespace: config.Namespace,
+				Labels:    map[string]string{labelManagedBy: managedByValue},
+			},
+			Spec: corev1.ResourceQuotaSpec{Hard: hard},
+		})
+	}
+
+	if b.LimitRange != nil {
+		item := corev1.LimitRangeItem{Type: corev1.LimitTypeContainer}
+		var err error
+		for _, field := range []struct {
+			target *corev1.ResourceList
+			values map[string]string
+		}{
+			{&item.Default, b.LimitRange.Default},
+			{&item.DefaultRequest, b.LimitRange.DefaultRequest},
+			{&item.Max, b.LimitRange.Max},
+			{&item.Min, b.LimitRange.Min},
+		} {
+			if *field.target, err = buildResourceList(field.values); err != nil {
+				return nil, fmt.Errorf("解析默认资源限制失败: %w", err)
+			}
+		}
+		objects = append(objects, &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      bootstrapObjectName,
+				Namespace: config.Namespace,
+				Labels:    map[string]string{labelManagedBy: managedByValue},
+			},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{item}},
+		})
+	}
+
+	var result []*unstructured.Unstructured
+	for _, obj := range objects {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// buildBootstrapSecret 构建镜像拉取 Secret，未配置时返回 nil
+func (r *deployRepo) buildBootstrapSecret(config *biz.K8sConfig, docker *biz.DockerConfig) (*unstructured.Unstructured, error) {
+	name := config.Bootstrap.ImagePullSecret
+	if name == "" {
+		return nil, nil
+	}
+	if docker == nil || docker.Username == "" || docker.Password == "" {
+		return nil, fmt.Errorf("生成镜像拉取 Secret %s 需要 Docker 仓库用户名和密码", name)
+	}
+	secret, err := buildPullSecret(config.Namespace, name, docker)
+	if err != nil {
+		return nil, err
+	}
+	return toUnstructured(secret)
+}
+
+// buildPullSecret 由 Docker 仓库凭证构建 dockerconfigjson 类型的 Secret
+func buildPullSecret(namespace, name string, docker *biz.DockerConfig) (*corev1.Secret, error) {
+	server := docker.Registry
+	if server == "" || server == "docker.io" {
+		server = dockerHubServer
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(docker.Username + ":" + docker.Password))
+	content, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			server: map[string]string{
+				"username": docker.Username,
+				"password": docker.Password,
+				"auth":     auth,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化镜像仓库凭证失败: %w", err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{labelManagedBy: managedByValue},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: content,
+		},
+	}, nil
+}
+
+// buildResourceList 解析资源列表，键为资源名称，如 cpu、memory、requests.cpu、pods
+func buildResourceList(values map[string]string) (corev1.ResourceList, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s 的取值 %q 无效: %w", name, value, err)
+		}
+		list[corev1.ResourceName(name)] = q
+	}
+	return list, nil
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	namespacesGVR     = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	resourceQuotasGVR = schema.GroupVersionResource{Version: "v1", Resource: "resourcequotas"}
+	secretsGVR        = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+func bootstrapTestConfig(manage bool) *biz.K8sConfig {
+	return &biz.K8sConfig{
+		Namespace:      "team-a",
+		DeploymentName: "demo",
+		Bootstrap: &biz.NamespaceBootstrap{
+			Labels:          map[string]string{"team": "a"},
+			ResourceQuota:   map[string]string{"pods": "20"},
+			ImagePullSecret: "regcred",
+			Manage:          manage,
+		},
+	}
+}
+
+var bootstrapTestDocker = &biz.DockerConfig{Registry: "registry.example.com", Username: "user", Password: "secret"}
+
+// TestBootstrapK8sNamespaceExisting 未接管时已存在的命名空间保持不变，缺少的对象被创建
+func TestBootstrapK8sNamespaceExisting(t *testing.T) {
+	existing := unstructuredObject("v1", "Namespace", "", "team-a", map[string]string{"owner": "platform"})
+	repo, k := newTestRepo(t, nil, existing)
+
+	if err := repo.BootstrapK8sNamespace(context.Background(), bootstrapTestConfig(false), bootstrapTestDocker); err != nil {
+		t.Fatalf("BootstrapK8sNamespace() error = %v", err)
+	}
+
+	ns := k.get(t, namespacesGVR, "", "team-a")
+	if labels := ns.GetLabels(); labels["owner"] != "platform" || labels["team"] != "" {
+		t.Errorf("已存在的命名空间被修改: labels = %v", labels)
+	}
+	if k.get(t, resourceQuotasGVR, "team-a", bootstrapObjectName) == nil {
+		t.Error("缺少的 ResourceQuota 未被创建")
+	}
+	if k.get(t, secretsGVR, "team-a", "regcred") == nil {
+		t.Error("镜像拉取 Secret 未被创建")
+	}
+}
+
+// TestBootstrapK8sNamespaceMissing 命名空间不存在时被创建
+func TestBootstrapK8sNamespaceMissing(t *testing.T) {
+	repo, k := newTestRepo(t, nil)
+
+	if err := repo.BootstrapK8sNamespace(context.Background(), bootstrapTestConfig(false), bootstrapTestDocker); err != nil {
+		t.Fatalf("BootstrapK8sNamespace() error = %v", err)
+	}
+
+	ns := k.get(t, namespacesGVR, "", "team-a")
+	if ns == nil {
+		t.Fatal("命名空间未被创建")
+	}
+	if ns.GetLabels()["team"] != "a" {
+		t.Errorf("命名空间 labels = %v", ns.GetLabels())
+	}
+}
+
+// TestBootstrapK8sNamespaceManage 接管时以服务端 apply 更新已存在的命名空间
+func TestBootstrapK8sNamespaceManage(t *testing.T) {
+	existing := unstructuredObject("v1", "Namespace", "", "team-a", map[string]string{"owner": "platform"})
+	repo, k := newTestRepo(t, nil, existing)
+
+	if err := repo.BootstrapK8sNamespace(context.Background(), bootstrapTestConfig(true), bootstrapTestDocker); err != nil {
+		t.Fatalf("BootstrapK8sNamespace() error = %v", err)
+	}
+
+	if labels := k.get(t, namespacesGVR, "", "team-a").GetLabels(); labels["team"] != "a" {
+		t.Errorf("接管的命名空间未更新: labels = %v", labels)
+	}
+}
+
+// TestDiffK8sBootstrap 对比结果包含初始化的对象，且与未接管时的部署行为一致
+func TestDiffK8sBootstrap(t *testing.T) {
+	existing := unstructuredObject("v1", "Namespace", "", "team-a", map[string]string{"owner": "platform"})
+	repo, _ := newTestRepo(t, nil, existing)
+
+	config := bootstrapTestConfig(false)
+	config.Image = "registry/demo:v1"
+	config.Replicas = 1
+	diffs, err := repo.DiffK8s(context.Background(), config, bootstrapTestDocker)
+	if err != nil {
+		t.Fatalf("DiffK8s() error = %v", err)
+	}
+
+	actions := map[string]biz.DiffAction{}
+	for _, d := range diffs {
+		actions[d.Kind+"/"+d.Name] = d.Action
+	}
+	want := map[string]biz.DiffAction{
+		"Namespace/team-a":                     biz.DiffUnchanged,
+		"ResourceQuota/" + bootstrapObjectName: biz.DiffCreate,
+		"Secret/regcred":                       biz.DiffCreate,
+	}
+	for id, action := range want {
+		if actions[id] != action {
+			t.Errorf("%s action = %q, want %q", id, actions[id], action)
+		}
+	}
+}
+
+// TestRenderK8sBootstrap 渲染结果包含初始化的对象，不包含含凭证的镜像拉取 Secret
+func TestRenderK8sBootstrap(t *testing.T) {
+	repo, _ := newTestRepo(t, nil)
+
+	config := bootstrapTestConfig(false)
+	config.Image = "registry/demo:v1"
+	config.Replicas = 1
+	manifests, err := repo.RenderK8s(context.Background(), config)
+	if err != nil {
+		t.Fatalf("RenderK8s() error = %v", err)
+	}
+
+	kinds := map[string]bool{}
+	for _, m := range manifests {
+		kinds[m.Kind] = true
+	}
+	if !kinds["Namespace"] || !kinds["ResourceQuota"] {
+		t.Errorf("渲染结果缺少初始化对象: %v", kinds)
+	}
+	if kinds["Secret"] {
+		t.Error("渲染结果包含镜像拉取 Secret")
+	}
+	if manifests[0].Kind != "Namespace" {
+		t.Errorf("第一个对象为 %s, want Namespace", manifests[0].Kind)
+	}
+}
@@ -0,0 +1,195 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// Notifier 通知渠道，按渠道配置的格式发送消息
+type Notifier interface {
+	Send(ctx context.Context, message *biz.Message) error
+}
+
+// NotifierFactory 根据渠道配置创建通知渠道
+type NotifierFactory func(config *biz.NotifierConfig, client *http.Client) (Notifier, error)
+
+var (
+	notifierMu        sync.RWMutex
+	notifierFactories = map[biz.NotifierType]NotifierFactory{}
+)
+
+// RegisterNotifier 注册通知渠道类型，重复注册时覆盖
+func RegisterNotifier(notifierType biz.NotifierType, factory NotifierFactory) {
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+	notifierFactories[notifierType] = factory
+}
+
+// newNotifier 按类型创建通知渠道，类型为空时为 Slack
+func newNotifier(config *biz.NotifierConfig, client *http.Client) (Notifier, error) {
+	notifierType := config.Type
+	if notifierType == "" {
+		notifierType = biz.NotifierSlack
+	}
+
+	notifierMu.RLock()
+	factory, ok := notifierFactories[notifierType]
+	notifierMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的通知类型: %s", notifierType)
+	}
+	return factory(config, client)
+}
+
+// SendNotification 向所有通知渠道发送消息，单个渠道失败不影响其余渠道；
+// 暂时性错误按策略重试，仍失败且配置了发件箱时写入发件箱，下次部署时补发
+func (r *deployRepo) SendNotification(ctx context.Context, config *biz.NotifyConfig, message *biz.Message) error {
+	r.log.WithContext(ctx).Infof("发送通知: %s", message.Title)
+
+	notifiers := config.AllNotifiers()
+	if len(notifiers) == 0 {
+		return fmt.Errorf("未配置通知渠道")
+	}
+
+	var errs []error
+	for _, nc := range notifiers {
+		name := notifierName(nc)
+		err := r.deliver(ctx, config, nc, message)
+		if err == nil {
+			r.log.WithContext(ctx).Infof("通知渠道 %s 发送成功", name)
+			continue
+		}
+
+		if config.OutboxDir != "" && isTransient(err) {
+			saveErr := saveOutbox(config.OutboxDir, nc, message, time.Now())
+			if saveErr == nil {
+				r.log.WithContext(ctx).Warnf("通知渠道 %s 发送失败，已写入发件箱等待补发: %v", name, err)
+				continue
+			}
+			r.log.WithContext(ctx).Warnf("写入通知发件箱失败: %v", saveErr)
+		}
+		r.log.WithContext(ctx).Warnf("通知渠道 %s 发送失败: %v", name, err)
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("通知发送失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// notifierName 返回通知渠道名称，默认为类型
+func notifierName(nc *biz.NotifierConfig) string {
+	if nc.Name != "" {
+		return nc.Name
+	}
+	if nc.Type != "" {
+		return string(nc.Type)
+	}
+	return string(biz.NotifierSlack)
+}
+
+// postJSON 以 JSON 格式发送请求，非 2xx 状态码视为失败；result 不为空时解析响应
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}, result interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化通知消息失败: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	body, err := post(ctx, client, url, jsonData, header)
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("解析通知响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// post 发送 POST 请求并返回响应体，非 2xx 状态码返回 httpStatusError
+func post(ctx context.Context, client *http.Client, url string, data []byte, header http.Header) ([]byte, error) {
+	if url == "" {
+		return nil, fmt.Errorf("Webhook URL 为空")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建通知请求失败: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送通知请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &httpStatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(body)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return body, nil
+}
+
+// plainText 生成纯文本消息，附带构建链接
+func plainText(message *biz.Message) string {
+	text := message.Text
+	if message.Link != "" && !strings.Contains(text, message.Link) {
+		text += "\n" + message.Link
+	}
+	return text
+}
+
+// markdownText 生成 markdown 正文：标题加粗，保留换行，末尾附带构建链接
+func markdownText(message *biz.Message, withLink bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n\n", message.Title)
+	b.WriteString(strings.ReplaceAll(message.Text, "\n", "  \n"))
+	if withLink && message.Link != "" {
+		fmt.Fprintf(&b, "\n\n[%s](%s)", message.LinkText, message.Link)
+	}
+	return b.String()
+}
+
+// truncate 按字符截断过长的文本
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// eventColor 返回事件对应的卡片颜色
+func eventColor(event biz.DeployEvent) string {
+	switch event {
+	case biz.EventSucceeded:
+		return "green"
+	case biz.EventFailed:
+		return "red"
+	case biz.EventRolledBack:
+		return "orange"
+	default:
+		return "blue"
+	}
+}
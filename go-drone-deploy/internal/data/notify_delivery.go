@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+const (
+	// defaultNotifyAttempts 默认最多发送次数
+	defaultNotifyAttempts = 3
+	// defaultNotifyBackoff 默认首次重试等待时间
+	defaultNotifyBackoff = time.Second
+	// defaultNotifyMaxBackoff 默认单次等待上限
+	defaultNotifyMaxBackoff = 30 * time.Second
+	// maxRetryAfter Retry-After 的上限，避免长时间阻塞部署
+	maxRetryAfter = time.Minute
+	// defaultOutboxMaxAge 发件箱中通知的默认保留时间
+	defaultOutboxMaxAge = 24 * time.Hour
+	// defaultOutboxFlushTimeout 补发发件箱的默认总超时时间
+	defaultOutboxFlushTimeout = time.Minute
+)
+
+// httpStatusError 通知渠道返回非 2xx 状态码
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("状态码: %d, 响应: %s", e.StatusCode, e.Body)
+}
+
+// providerError 通知渠道在响应体中返回的业务错误
+type providerError struct {
+	Provider  string
+	Code      int
+	Message   string
+	Retryable bool
+}
+
+func (e *providerError) Error() string {
+	return fmt.Sprintf("%s返回错误 %d: %s", e.Provider, e.Code, e.Message)
+}
+
+// isTransient 判断错误是否为暂时性错误，可以重试
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var providerErr *providerError
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable
+	}
+	// SMTP 4xx 为暂时性错误
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter 返回服务端要求的等待时间
+func retryAfter(err error) time.Duration {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter 解析 Retry-After，支持秒数和 HTTP 日期
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// deliver 向单个渠道发送通知，暂时性错误按指数退避重试
+func (r *deployRepo) deliver(ctx context.Context, config *biz.NotifyConfig, nc *biz.NotifierConfig, message *biz.Message) error {
+	notifier, err := newNotifier(nc, r.data.httpClient)
+	if err != nil {
+		return err
+	}
+	return r.retry(ctx, config, notifierName(nc), func(ctx context.Context) error {
+		return notifier.Send(ctx, message)
+	})
+}
+
+// retry 按通知配置的超时和重试策略执行发送，暂时性错误按指数退避重试
+func (r *deployRepo) retry(ctx context.Context, config *biz.NotifyConfig, name string, send func(ctx context.Context) error) error {
+	attempts, backoff, maxBackoff := defaultNotifyAttempts, defaultNotifyBackoff, defaultNotifyMaxBackoff
+	if retry := config.Retry; retry != nil {
+		if retry.MaxAttempts > 0 {
+			attempts = retry.MaxAttempts
+		}
+		if retry.InitialBackoff > 0 {
+			backoff = retry.InitialBackoff
+		}
+		if retry.MaxBackoff > 0 {
+			maxBackoff = retry.MaxBackoff
+		}
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := send(attemptCtx)
+		cancel()
+		if err == nil || attempt >= attempts || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// 指数退避加 ±20% 抖动，服务端给出 Retry-After 时使用它
+		wait := time.Duration(float64(backoff) * (0.8 + 0.4*rand.Float64()))
+		if after := retryAfter(err); after > 0 {
+			wait = min(after, maxRetryAfter)
+		}
+		r.log.WithContext(ctx).Warnf("通知渠道 %s 第 %d 次发送失败，%s 后重试: %v", name, attempt, wait.Round(time.Millisecond), err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// outboxEntry 发件箱中的通知，只记录渠道标识，不保存渠道密钥
+type outboxEntry struct {
+	Notifier  string       `json:"notifier"`
+	Message   *biz.Message `json:"message"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// notifierKey 渠道标识，由类型、名称和地址摘要组成
+func notifierKey(nc *biz.NotifierConfig) string {
+	target := nc.WebhookURL
+	if nc.SMTP != nil {
+		target = fmt.Sprintf("%s:%d:%s", nc.SMTP.Host, nc.SMTP.Port, strings.Join(append(append([]string{}, nc.SMTP.To...), nc.SMTP.Cc...), ","))
+	}
+	sum := sha256.Sum256([]byte(target))
+	return fmt.Sprintf("%s/%s/%s", nc.Type, notifierName(nc), hex.EncodeToString(sum[:6]))
+}
+
+// saveOutbox 将未送达的通知写入发件箱
+func saveOutbox(dir string, nc *biz.NotifierConfig, message *biz.Message, now time.Time) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("创建发件箱目录失败: %w", err)
+	}
+
+	content, err := json.Marshal(&outboxEntry{Notifier: notifierKey(nc), Message: message, CreatedAt: now})
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+
+	random := make([]byte, 4)
+	_, _ = cryptorand.Read(random)
+	name := fmt.Sprintf("%d-%s.json", now.UnixNano(), hex.EncodeToString(random))
+	// 先写临时文件再重命名，避免补发时读到不完整的文件
+	tmp := filepath.Join(dir, "."+name)
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("写入发件箱失败: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("写入发件箱失败: %w", err)
+	}
+	return nil
+}
+
+// FlushNotificationOutbox 按写入顺序补发发件箱中的通知；渠道已不存在或超过保留时间的通知会被丢弃。
+// 某个渠道出现暂时性错误后不再补发它的其余通知，超过总超时时间后停止补发，未补发的通知留待下次
+func (r *deployRepo) FlushNotificationOutbox(ctx context.Context, config *biz.NotifyConfig) (int, error) {
+	if config.OutboxDir == "" {
+		return 0, nil
+	}
+
+	timeout := config.OutboxFlushTimeout
+	if timeout <= 0 {
+		timeout = defaultOutboxFlushTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	files, err := filepath.Glob(filepath.Join(config.OutboxDir, "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("读取发件箱失败: %w", err)
+	}
+	sort.Strings(files)
+
+	maxAge := config.OutboxMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultOutboxMaxAge
+	}
+	notifiers := map[string]*biz.NotifierConfig{}
+	for _, nc := range config.AllNotifiers() {
+		notifiers[notifierKey(nc)] = nc
+	}
+
+	sent := 0
+	var errs []error
+	// unavailable 本次补发中出现暂时性错误的渠道
+	unavailable := map[string]bool{}
+	for _, file := range files {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("补发超过 %s，其余通知留待下次补发: %w", timeout, ctx.Err()))
+			break
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("读取 %s 失败: %w", filepath.Base(file), err))
+			continue
+		}
+		var entry outboxEntry
+		if err := json.Unmarshal(content, &entry); err != nil || entry.Message == nil {
+			r.log.WithContext(ctx).Warnf("发件箱文件 %s 无效，已丢弃", filepath.Base(file))
+			_ = os.Remove(file)
+			continue
+		}
+
+		nc, ok := notifiers[entry.Notifier]
+		switch {
+		case !ok:
+			r.log.WithContext(ctx).Warnf("通知渠道 %s 已不在配置中，丢弃通知: %s", entry.Notifier, entry.Message.Title)
+			_ = os.Remove(file)
+			continue
+		case time.Since(entry.CreatedAt) > maxAge:
+			r.log.WithContext(ctx).Warnf("通知已超过保留时间 %s，丢弃: %s", maxAge, entry.Message.Title)
+			_ = os.Remove(file)
+			continue
+		case unavailable[entry.Notifier]:
+			continue
+		}
+
+		if err := r.deliver(ctx, config, nc, entry.Message); err != nil {
+			// 暂时性错误保留，等待下次补发，且本次不再向该渠道补发
+			if isTransient(err) {
+				unavailable[entry.Notifier] = true
+				r.log.WithContext(ctx).Warnf("通知渠道 %s 暂时不可用，其余通知留待下次补发", notifierName(nc))
+			} else {
+				_ = os.Remove(file)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", notifierName(nc), err))
+			continue
+		}
+		_ = os.Remove(file)
+		sent++
+	}
+
+	if len(errs) > 0 {
+		return sent, errors.Join(errs...)
+	}
+	return sent, nil
+}
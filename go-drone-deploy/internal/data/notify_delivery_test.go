@@ -0,0 +1,96 @@
+package data
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// TestFlushNotificationOutbox 渠道出现暂时性错误后不再补发它的其余通知，其余渠道照常补发
+func TestFlushNotificationOutbox(t *testing.T) {
+	var down, up atomic.Int32
+	downServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		down.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer downServer.Close()
+	upServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		up.Add(1)
+	}))
+	defer upServer.Close()
+
+	downNotifier := &biz.NotifierConfig{Name: "down", Type: biz.NotifierSlack, WebhookURL: downServer.URL}
+	upNotifier := &biz.NotifierConfig{Name: "up", Type: biz.NotifierSlack, WebhookURL: upServer.URL}
+	config := &biz.NotifyConfig{
+		Notifiers: []*biz.NotifierConfig{downNotifier, upNotifier},
+		Retry:     &biz.NotifyRetry{MaxAttempts: 1},
+		OutboxDir: t.TempDir(),
+	}
+
+	now := time.Now()
+	for i, nc := range []*biz.NotifierConfig{downNotifier, downNotifier, upNotifier, downNotifier} {
+		if err := saveOutbox(config.OutboxDir, nc, &biz.Message{Title: "部署成功"}, now.Add(time.Duration(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo, _ := newTestRepo(t, nil)
+	sent, err := repo.FlushNotificationOutbox(context.Background(), config)
+	if err == nil {
+		t.Fatal("FlushNotificationOutbox() error = nil, want 暂时性错误")
+	}
+	if sent != 1 || up.Load() != 1 {
+		t.Errorf("sent = %d, up 请求数 = %d, want 1", sent, up.Load())
+	}
+	if down.Load() != 1 {
+		t.Errorf("down 请求数 = %d, want 1", down.Load())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(config.OutboxDir, "*.json"))
+	if len(files) != 3 {
+		t.Errorf("发件箱剩余 %d 条通知, want 3", len(files))
+	}
+}
+
+// TestFlushNotificationOutboxTimeout 超过总超时时间后停止补发，通知留在发件箱
+func TestFlushNotificationOutboxTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	nc := &biz.NotifierConfig{Name: "slow", Type: biz.NotifierSlack, WebhookURL: server.URL}
+	config := &biz.NotifyConfig{
+		Notifiers:          []*biz.NotifierConfig{nc},
+		Timeout:            time.Minute,
+		OutboxDir:          t.TempDir(),
+		OutboxFlushTimeout: 50 * time.Millisecond,
+	}
+	if err := saveOutbox(config.OutboxDir, nc, &biz.Message{Title: "部署成功"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, _ := newTestRepo(t, nil)
+	start := time.Now()
+	if _, err := repo.FlushNotificationOutbox(context.Background(), config); err == nil {
+		t.Fatal("FlushNotificationOutbox() error = nil, want 超时")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("补发耗时 %s，未受总超时限制", elapsed)
+	}
+	if entries, _ := os.ReadDir(config.OutboxDir); len(entries) != 1 {
+		t.Errorf("发件箱剩余 %d 个文件, want 1", len(entries))
+	}
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// dingTalkRetryableCodes 可重试的钉钉错误码：发送过快、系统繁忙
+var dingTalkRetryableCodes = map[int]bool{130101: true, -1: true}
+
+func init() {
+	RegisterNotifier(biz.NotifierDingTalk, newDingTalkNotifier)
+}
+
+// dingTalkNotifier 钉钉自定义机器人
+type dingTalkNotifier struct {
+	config *biz.NotifierConfig
+	client *http.Client
+}
+
+func newDingTalkNotifier(config *biz.NotifierConfig, client *http.Client) (Notifier, error) {
+	return &dingTalkNotifier{config: config, client: client}, nil
+}
+
+// Send 发送消息，配置了密钥时在 URL 上附加 timestamp 与 sign；
+// 富文本格式使用 markdown，有构建链接时使用 actionCard
+func (n *dingTalkNotifier) Send(ctx context.Context, message *biz.Message) error {
+	webhookURL := n.config.WebhookURL
+	if n.config.Secret != "" {
+		signed, err := dingTalkSignedURL(webhookURL, n.config.Secret, time.Now())
+		if err != nil {
+			return err
+		}
+		webhookURL = signed
+	}
+
+	var payload map[string]interface{}
+	switch {
+	case n.config.Format != biz.MessageRich:
+		payload = map[string]interface{}{
+			"msgtype": "text",
+			"text": map[string]string{
+				"content": plainText(message),
+			},
+		}
+	case message.Link != "":
+		payload = map[string]interface{}{
+			"msgtype": "actionCard",
+			"actionCard": map[string]string{
+				"title":       message.Title,
+				"text":        markdownText(message, false),
+				"singleTitle": message.LinkText,
+				"singleURL":   message.Link,
+			},
+		}
+	default:
+		payload = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": message.Title,
+				"text":  markdownText(message, false),
+			},
+		}
+	}
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(ctx, n.client, webhookURL, payload, &result); err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return &providerError{Provider: "钉钉", Code: result.ErrCode, Message: result.ErrMsg, Retryable: dingTalkRetryableCodes[result.ErrCode]}
+	}
+	return nil
+}
+
+// dingTalkSignedURL 签名为 Base64(HmacSHA256(timestamp + "\n" + secret))，timestamp 为毫秒
+func dingTalkSignedURL(webhookURL, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("解析钉钉 Webhook URL 失败: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", sign)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
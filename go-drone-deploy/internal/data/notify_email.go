@@ -0,0 +1,254 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+const (
+	// defaultSMTPTimeout 未设置上下文截止时间时 SMTP 会话的超时时间
+	defaultSMTPTimeout = 30 * time.Second
+)
+
+// emailHTML 邮件 HTML 正文
+var emailHTML = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, 'Helvetica Neue', Arial, sans-serif; color: #1f2329;">
+<h2 style="border-left: 4px solid {{.Color}}; padding-left: 8px;">{{.Title}}</h2>
+<pre style="font-family: inherit; white-space: pre-wrap; line-height: 1.6;">{{.Text}}</pre>
+{{if .Link}}<p><a href="{{.Link}}" style="display: inline-block; padding: 6px 14px; background: #3370ff; color: #fff; text-decoration: none; border-radius: 4px;">{{.LinkText}}</a></p>{{end}}
+</body>
+</html>
+`))
+
+// emailColors 事件对应的 HTML 颜色
+var emailColors = map[string]string{
+	"green":  "#34c724",
+	"red":    "#f54a45",
+	"orange": "#ff8800",
+	"blue":   "#3370ff",
+}
+
+func init() {
+	RegisterNotifier(biz.NotifierEmail, newEmailNotifier)
+}
+
+// emailNotifier SMTP 邮件
+type emailNotifier struct {
+	config *biz.SMTPConfig
+	// timeout 未设置上下文截止时间时的会话超时
+	timeout time.Duration
+}
+
+func newEmailNotifier(config *biz.NotifierConfig, client *http.Client) (Notifier, error) {
+	smtpConfig := config.SMTP
+	if smtpConfig == nil || smtpConfig.Host == "" {
+		return nil, fmt.Errorf("未配置 SMTP 服务器")
+	}
+	if smtpConfig.From == "" || len(smtpConfig.To)+len(smtpConfig.Cc) == 0 {
+		return nil, fmt.Errorf("未配置发件人或收件人")
+	}
+
+	timeout := defaultSMTPTimeout
+	if client != nil && client.Timeout > 0 {
+		timeout = client.Timeout
+	}
+	return &emailNotifier{config: smtpConfig, timeout: timeout}, nil
+}
+
+// Send 发送 HTML 与纯文本两种正文的邮件
+func (n *emailNotifier) Send(ctx context.Context, message *biz.Message) error {
+	body, err := n.buildMessage(message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := n.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if n.config.Username != "" {
+		auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(addressOf(n.config.From)); err != nil {
+		return fmt.Errorf("设置发件人失败: %w", err)
+	}
+	for _, rcpt := range append(append([]string{}, n.config.To...), n.config.Cc...) {
+		if err := client.Rcpt(addressOf(rcpt)); err != nil {
+			return fmt.Errorf("设置收件人 %s 失败: %w", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// dial 连接 SMTP 服务器并按配置建立 TLS
+func (n *emailNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	port := n.config.Port
+	security := n.config.Security
+	if security == "" {
+		security = biz.SMTPStartTLS
+	}
+	if port == 0 {
+		port = 587
+		if security == biz.SMTPTLS {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: n.config.Host, InsecureSkipVerify: n.config.InsecureSkipVerify}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(n.timeout)
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+
+	var conn net.Conn
+	var err error
+	if security == biz.SMTPTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	// 整个会话共用截止时间，避免服务器无响应时卡住部署
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("建立 SMTP 会话失败: %w", err)
+	}
+
+	if security == biz.SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// buildMessage 构建 multipart/alternative 邮件
+func (n *emailNotifier) buildMessage(message *biz.Message, now time.Time) ([]byte, error) {
+	var htmlBody bytes.Buffer
+	err := emailHTML.Execute(&htmlBody, map[string]string{
+		"Title":    message.Title,
+		"Text":     message.Text,
+		"Link":     message.Link,
+		"LinkText": message.LinkText,
+		"Color":    emailColors[eventColor(message.Event)],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("渲染邮件 HTML 失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := []struct{ key, value string }{
+		{"From", n.config.From},
+		{"To", strings.Join(n.config.To, ", ")},
+		{"Cc", strings.Join(n.config.Cc, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Title)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", messageID(n.config.From, now)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	var head bytes.Buffer
+	for _, h := range headers {
+		if h.value != "" {
+			fmt.Fprintf(&head, "%s: %s\r\n", h.key, h.value)
+		}
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", plainText(message)},
+		{"text/html; charset=utf-8", htmlBody.String()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("构建邮件正文失败: %w", err)
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("构建邮件正文失败: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("构建邮件正文失败: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("构建邮件正文失败: %w", err)
+	}
+
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// addressOf 从 "Name <addr>" 形式中取出邮件地址
+func addressOf(address string) string {
+	if i := strings.LastIndex(address, "<"); i >= 0 {
+		if j := strings.LastIndex(address, ">"); j > i {
+			return address[i+1 : j]
+		}
+	}
+	return strings.TrimSpace(address)
+}
+
+// messageID 生成 Message-ID，域名取自发件人地址
+func messageID(from string, now time.Time) string {
+	domain := "localhost"
+	if addr := addressOf(from); strings.Contains(addr, "@") {
+		domain = addr[strings.LastIndex(addr, "@")+1:]
+	}
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), hex.EncodeToString(random), domain)
+}
@@ -0,0 +1,272 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// smtpSession 假 SMTP 服务器记录的一次会话
+type smtpSession struct {
+	// authTLS AUTH 命令是否在 TLS 之上发送
+	authTLS bool
+	auth    string
+	from    string
+	rcpts   []string
+	data    string
+}
+
+// fakeSMTPServer 进程内的假 SMTP 服务器，支持 STARTTLS 与 AUTH PLAIN，只处理一个连接
+type fakeSMTPServer struct {
+	addr     string
+	sessions chan *smtpSession
+}
+
+func newFakeSMTPServer(t *testing.T, startTLS bool) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}
+	s := &fakeSMTPServer{addr: ln.Addr().String(), sessions: make(chan *smtpSession, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.sessions <- serveSMTP(conn, tlsConfig, startTLS)
+	}()
+	return s
+}
+
+// serveSMTP 处理一个 SMTP 会话
+func serveSMTP(conn net.Conn, tlsConfig *tls.Config, startTLS bool) *smtpSession {
+	session := &smtpSession{}
+	tp := textproto.NewConn(conn)
+	secure := false
+	_ = tp.PrintfLine("220 fake ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return session
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			if startTLS && !secure {
+				_ = tp.PrintfLine("250-fake\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			} else {
+				_ = tp.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return session
+			}
+			conn, secure = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			_, credentials, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			session.auth, session.authTLS = string(decoded), secure
+			_ = tp.PrintfLine("235 ok")
+		case "MAIL":
+			session.from = strings.TrimPrefix(arg, "FROM:")
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			session.rcpts = append(session.rcpts, strings.TrimPrefix(arg, "TO:"))
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return session
+			}
+			session.data = string(data)
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return session
+		default:
+			_ = tp.PrintfLine("502 unsupported")
+		}
+	}
+}
+
+// selfSignedCert 生成 127.0.0.1 的自签名证书
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func emailTestNotifier(t *testing.T, addr string) Notifier {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+	notifier, err := newEmailNotifier(&biz.NotifierConfig{
+		Type: biz.NotifierEmail,
+		SMTP: &biz.SMTPConfig{
+			Host:               host,
+			Port:               portNum,
+			Security:           biz.SMTPStartTLS,
+			InsecureSkipVerify: true,
+			Username:           "deploy",
+			Password:           "secret",
+			From:               "Deploy Bot <deploy@example.com>",
+			To:                 []string{"dev@example.com"},
+			Cc:                 []string{"Ops <ops@example.com>"},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notifier
+}
+
+// TestEmailNotifierSend 经 STARTTLS 后认证，投递给全部收件人，正文包含纯文本与 HTML 两部分
+func TestEmailNotifierSend(t *testing.T) {
+	server := newFakeSMTPServer(t, true)
+	message := &biz.Message{
+		Event: biz.EventSucceeded,
+		Title: "部署成功: demo",
+		Text:  "版本 v1.2.0 已发布到 prod",
+		Link:  "https://ci.example.com/builds/42",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := emailTestNotifier(t, server.addr).Send(ctx, message); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	session := <-server.sessions
+
+	if !session.authTLS {
+		t.Error("AUTH 未在 STARTTLS 之后发送")
+	}
+	if session.auth != "\x00deploy\x00secret" {
+		t.Errorf("AUTH PLAIN = %q", session.auth)
+	}
+	if session.from != "<deploy@example.com>" {
+		t.Errorf("MAIL FROM = %s", session.from)
+	}
+	if got := strings.Join(session.rcpts, ","); got != "<dev@example.com>,<ops@example.com>" {
+		t.Errorf("RCPT TO = %s", got)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != message.Title {
+		t.Errorf("Subject = %q, %v, want %q", subject, err, message.Title)
+	}
+	if msg.Header.Get("Cc") != "Ops <ops@example.com>" {
+		t.Errorf("Cc = %q", msg.Header.Get("Cc"))
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("解析 Content-Type 失败: %v", err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取邮件正文失败: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[mediaType] = string(body)
+	}
+	if text := parts["text/plain"]; !strings.Contains(text, message.Text) || !strings.Contains(text, message.Link) {
+		t.Errorf("纯文本正文 = %q", text)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, message.Title) || !strings.Contains(html, `href="`+message.Link+`"`) {
+		t.Errorf("HTML 正文 = %q", html)
+	}
+}
+
+// TestEmailNotifierRequiresStartTLS 服务器不支持 STARTTLS 时不以明文发送凭证
+func TestEmailNotifierRequiresStartTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := emailTestNotifier(t, server.addr).Send(ctx, &biz.Message{Title: "部署成功"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send() error = %v, want 不支持 STARTTLS", err)
+	}
+	if session := <-server.sessions; session.auth != "" {
+		t.Error("未建立 TLS 时发送了 AUTH")
+	}
+}
+
+// TestEmailNotifierTransient SMTP 4xx 为暂时性错误
+func TestEmailNotifierTransient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("421 服务暂时不可用\r\n"))
+		_, _ = bufio.NewReader(conn).ReadString('\n')
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = emailTestNotifier(t, ln.Addr().String()).Send(ctx, &biz.Message{Title: "部署成功"})
+	if err == nil || !isTransient(err) {
+		t.Fatalf("Send() error = %v, want 暂时性错误", err)
+	}
+}
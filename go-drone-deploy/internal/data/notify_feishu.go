@@ -0,0 +1,101 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// feishuRetryableCodes 可重试的飞书错误码：请求过多、频率受限
+var feishuRetryableCodes = map[int]bool{9499: true, 11232: true}
+
+func init() {
+	RegisterNotifier(biz.NotifierFeishu, newFeishuNotifier)
+}
+
+// feishuNotifier 飞书 / Lark 自定义机器人
+type feishuNotifier struct {
+	config *biz.NotifierConfig
+	client *http.Client
+}
+
+func newFeishuNotifier(config *biz.NotifierConfig, client *http.Client) (Notifier, error) {
+	return &feishuNotifier{config: config, client: client}, nil
+}
+
+// Send 发送消息，配置了密钥时在消息体中附加 timestamp 与 sign；富文本格式使用消息卡片
+func (n *feishuNotifier) Send(ctx context.Context, message *biz.Message) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content": map[string]string{
+			"text": plainText(message),
+		},
+	}
+	if n.config.Format == biz.MessageRich {
+		payload = map[string]interface{}{
+			"msg_type": "interactive",
+			"card":     feishuCard(message),
+		}
+	}
+	if n.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = feishuSign(timestamp, n.config.Secret)
+	}
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(ctx, n.client, n.config.WebhookURL, payload, &result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return &providerError{Provider: "飞书", Code: result.Code, Message: result.Msg, Retryable: feishuRetryableCodes[result.Code]}
+	}
+	return nil
+}
+
+// feishuSign 签名以 timestamp + "\n" + secret 为密钥，对空数据做 HmacSHA256 后 Base64，timestamp 为秒
+func feishuSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// feishuCard 构建消息卡片：按事件着色的标题、正文及构建链接按钮
+func feishuCard(message *biz.Message) map[string]interface{} {
+	elements := []map[string]interface{}{
+		{
+			"tag":  "div",
+			"text": map[string]string{"tag": "lark_md", "content": message.Text},
+		},
+	}
+	if message.Link != "" {
+		elements = append(elements, map[string]interface{}{
+			"tag": "action",
+			"actions": []map[string]interface{}{
+				{
+					"tag":  "button",
+					"text": map[string]string{"tag": "plain_text", "content": message.LinkText},
+					"url":  message.Link,
+					"type": "primary",
+				},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"config": map[string]bool{"wide_screen_mode": true},
+		"header": map[string]interface{}{
+			"title":    map[string]string{"tag": "plain_text", "content": message.Title},
+			"template": eventColor(message.Event),
+		},
+		"elements": elements,
+	}
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"net/http"
+
+	"go-drone-deploy/internal/biz"
+)
+
+const (
+	// slackSectionLimit Slack section 文本的长度上限
+	slackSectionLimit = 3000
+	// slackHeaderLimit Slack header 文本的长度上限
+	slackHeaderLimit = 150
+)
+
+func init() {
+	RegisterNotifier(biz.NotifierSlack, newSlackNotifier)
+}
+
+// slackNotifier Slack Incoming Webhook
+type slackNotifier struct {
+	config *biz.NotifierConfig
+	client *http.Client
+}
+
+func newSlackNotifier(config *biz.NotifierConfig, client *http.Client) (Notifier, error) {
+	return &slackNotifier{config: config, client: client}, nil
+}
+
+// Send 发送文本消息，富文本格式使用 Block Kit
+func (n *slackNotifier) Send(ctx context.Context, message *biz.Message) error {
+	payload := map[string]interface{}{
+		"text": plainText(message),
+	}
+	if n.config.Format == biz.MessageRich {
+		payload["text"] = message.Title
+		payload["blocks"] = slackBlocks(message)
+	}
+	if n.config.Channel != "" {
+		payload["channel"] = n.config.Channel
+	}
+	return postJSON(ctx, n.client, n.config.WebhookURL, payload, nil)
+}
+
+// slackBlocks 构建 Block Kit：标题、正文及构建链接按钮
+func slackBlocks(message *biz.Message) []map[string]interface{} {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]string{"type": "plain_text", "text": truncate(message.Title, slackHeaderLimit)},
+		},
+		{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": truncate(message.Text, slackSectionLimit)},
+		},
+	}
+	if message.Link != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{
+				{
+					"type": "button",
+					"text": map[string]string{"type": "plain_text", "text": message.LinkText},
+					"url":  message.Link,
+				},
+			},
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,354 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+)
+
+const (
+	// webhookSchemaVersion 部署事件的数据版本，字段有不兼容变更时递增
+	webhookSchemaVersion = "go-drone-deploy.deploy-event/v1"
+	// cloudEventTypePrefix CloudEvents 事件类型前缀
+	cloudEventTypePrefix = "io.go-drone-deploy.deploy."
+
+	// Webhook 请求头
+	headerEvent     = "X-Deploy-Event"
+	headerDelivery  = "X-Deploy-Delivery"
+	headerTimestamp = "X-Deploy-Timestamp"
+	headerSignature = "X-Deploy-Signature"
+	headerReplay    = "X-Deploy-Replay"
+)
+
+// webhookPayload 部署事件的 JSON 数据
+type webhookPayload struct {
+	SchemaVersion string           `json:"schemaVersion"`
+	ID            string           `json:"id"`
+	Type          biz.DeployEvent  `json:"type"`
+	Time          time.Time        `json:"time"`
+	DeployID      string           `json:"deployId"`
+	Flow          biz.DeployFlow   `json:"flow"`
+	Service       string           `json:"service"`
+	Env           string           `json:"env"`
+	Version       string           `json:"version"`
+	Image         string           `json:"image,omitempty"`
+	ImageDigest   string           `json:"imageDigest,omitempty"`
+	Author        string           `json:"author,omitempty"`
+	Commit        string           `json:"commit,omitempty"`
+	BuildLink     string           `json:"buildLink,omitempty"`
+	StartedAt     time.Time        `json:"startedAt"`
+	DurationMs    int64            `json:"durationMs"`
+	Steps         []*webhookStep   `json:"steps"`
+	Targets       []*webhookTarget `json:"targets,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	RolledBack    []string         `json:"rolledBack,omitempty"`
+	Replay        bool             `json:"replay,omitempty"`
+}
+
+// webhookStep 部署步骤结果
+type webhookStep struct {
+	Name       string         `json:"name"`
+	Status     biz.StepStatus `json:"status"`
+	StartedAt  time.Time      `json:"startedAt"`
+	DurationMs int64          `json:"durationMs"`
+	Attempts   int            `json:"attempts,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// webhookTarget 目标集群结果
+type webhookTarget struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	RolledBack bool   `json:"rolledBack,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// cloudEvent CloudEvents 1.0 结构化格式
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            *webhookPayload `json:"data"`
+}
+
+// newWebhookPayload 将部署事件转换为 JSON 数据
+func newWebhookPayload(event *biz.WebhookEvent) *webhookPayload {
+	payload := &webhookPayload{
+		SchemaVersion: webhookSchemaVersion,
+		ID:            event.ID,
+		Type:          event.Type,
+		Time:          event.Time.UTC(),
+		DeployID:      event.DeployID,
+		Flow:          event.Flow,
+		Service:       event.Service,
+		Env:           event.Env,
+		Version:       event.Version,
+		Image:         event.Image,
+		ImageDigest:   event.ImageDigest,
+		Author:        event.Author,
+		Commit:        event.Commit,
+		BuildLink:     event.BuildLink,
+		StartedAt:     event.StartedAt.UTC(),
+		DurationMs:    event.Duration.Milliseconds(),
+		Steps:         []*webhookStep{},
+		Error:         event.Error,
+		RolledBack:    event.RolledBack,
+		Replay:        event.Replay,
+	}
+	for _, step := range event.Steps {
+		s := &webhookStep{
+			Name:       step.Name,
+			Status:     step.Status,
+			StartedAt:  step.StartedAt.UTC(),
+			DurationMs: step.Duration.Milliseconds(),
+			Attempts:   step.Attempts,
+		}
+		if step.Err != nil {
+			s.Error = step.Err.Error()
+		}
+		payload.Steps = append(payload.Steps, s)
+	}
+	for _, target := range event.Targets {
+		t := &webhookTarget{
+			Name:       target.Target,
+			Namespace:  target.Namespace,
+			Skipped:    target.Skipped,
+			RolledBack: target.RolledBack,
+			DurationMs: target.Duration.Milliseconds(),
+		}
+		if target.Err != nil {
+			t.Error = target.Err.Error()
+		}
+		payload.Targets = append(payload.Targets, t)
+	}
+	return payload
+}
+
+// event 将保存的 JSON 数据还原为部署事件
+func (p *webhookPayload) event() *biz.WebhookEvent {
+	event := &biz.WebhookEvent{
+		ID:          p.ID,
+		Type:        p.Type,
+		Time:        p.Time,
+		DeployID:    p.DeployID,
+		Flow:        p.Flow,
+		Service:     p.Service,
+		Env:         p.Env,
+		Version:     p.Version,
+		Image:       p.Image,
+		ImageDigest: p.ImageDigest,
+		Author:      p.Author,
+		Commit:      p.Commit,
+		BuildLink:   p.BuildLink,
+		StartedAt:   p.StartedAt,
+		Duration:    time.Duration(p.DurationMs) * time.Millisecond,
+		Error:       p.Error,
+		RolledBack:  p.RolledBack,
+	}
+	for _, s := range p.Steps {
+		step := &biz.StepResult{
+			Name:      s.Name,
+			Status:    s.Status,
+			StartedAt: s.StartedAt,
+			Duration:  time.Duration(s.DurationMs) * time.Millisecond,
+			Attempts:  s.Attempts,
+		}
+		if s.Error != "" {
+			step.Err = errors.New(s.Error)
+		}
+		event.Steps = append(event.Steps, step)
+	}
+	for _, t := range p.Targets {
+		target := &biz.TargetResult{
+			Target:     t.Name,
+			Namespace:  t.Namespace,
+			Skipped:    t.Skipped,
+			RolledBack: t.RolledBack,
+			Duration:   time.Duration(t.DurationMs) * time.Millisecond,
+		}
+		if t.Error != "" {
+			target.Err = errors.New(t.Error)
+		}
+		event.Targets = append(event.Targets, target)
+	}
+	return event
+}
+
+// webhookName 返回 Webhook 名称，默认为 URL 的主机名
+func webhookName(w *biz.WebhookConfig) string {
+	if w.Name != "" {
+		return w.Name
+	}
+	if u, err := url.Parse(w.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "webhook"
+}
+
+// encodeWebhook 按 Webhook 格式编码事件，返回请求体和 Content-Type
+func encodeWebhook(w *biz.WebhookConfig, payload *webhookPayload) ([]byte, string, error) {
+	switch w.Format {
+	case "", biz.WebhookJSON:
+		data, err := json.Marshal(payload)
+		return data, "application/json", err
+	case biz.WebhookCloudEvents:
+		data, err := json.Marshal(&cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              payload.ID,
+			Source:          fmt.Sprintf("/go-drone-deploy/%s/%s", payload.Service, payload.Env),
+			Type:            cloudEventTypePrefix + string(payload.Type),
+			Subject:         payload.DeployID,
+			Time:            payload.Time,
+			DataContentType: "application/json",
+			Data:            payload,
+		})
+		return data, "application/cloudevents+json", err
+	default:
+		return nil, "", fmt.Errorf("不支持的 Webhook 格式: %s", w.Format)
+	}
+}
+
+// signWebhook 计算签名：HMAC-SHA256(secret, timestamp + "." + body)，十六进制编码
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendWebhookEvent 向每个 Webhook 推送部署事件，单个 Webhook 失败不影响其余 Webhook；
+// 暂时性错误按通知配置的策略重试，签名中的时间戳每次重试时更新
+func (r *deployRepo) SendWebhookEvent(ctx context.Context, config *biz.NotifyConfig, webhooks []*biz.WebhookConfig, event *biz.WebhookEvent) error {
+	payload := newWebhookPayload(event)
+
+	var errs []error
+	for _, w := range webhooks {
+		name := webhookName(w)
+		body, contentType, err := encodeWebhook(w, payload)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: 编码部署事件失败: %w", name, err))
+			continue
+		}
+
+		err = r.retry(ctx, config, name, func(ctx context.Context) error {
+			header := http.Header{}
+			for key, value := range w.Headers {
+				header.Set(key, value)
+			}
+			header.Set("Content-Type", contentType)
+			header.Set("User-Agent", managedByValue)
+			header.Set(headerEvent, string(event.Type))
+			header.Set(headerDelivery, event.ID)
+			if event.Replay {
+				header.Set(headerReplay, "true")
+			}
+			if w.Secret != "" {
+				timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+				header.Set(headerTimestamp, timestamp)
+				header.Set(headerSignature, signWebhook(w.Secret, timestamp, body))
+			}
+			_, err := post(ctx, r.data.httpClient, w.URL, body, header)
+			return err
+		})
+		if err != nil {
+			r.log.WithContext(ctx).Warnf("Webhook %s 推送 %s 事件失败: %v", name, event.Type, err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		r.log.WithContext(ctx).Infof("Webhook %s 推送 %s 事件成功", name, event.Type)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Webhook 推送失败: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// SaveWebhookEvent 将部署事件保存到 <EventDir>/<部署 ID>/ 下，文件名按时间排序
+func (r *deployRepo) SaveWebhookEvent(ctx context.Context, config *biz.NotifyConfig, event *biz.WebhookEvent) error {
+	if !validEventID(event.DeployID) || !validEventID(event.ID) {
+		return fmt.Errorf("无效的部署事件标识: %s/%s", event.DeployID, event.ID)
+	}
+
+	dir := filepath.Join(config.EventDir, event.DeployID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("创建部署事件目录失败: %w", err)
+	}
+
+	content, err := json.MarshalIndent(newWebhookPayload(event), "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化部署事件失败: %w", err)
+	}
+
+	name := fmt.Sprintf("%d-%s-%s.json", event.Time.UnixNano(), event.Type, event.ID)
+	if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+		return fmt.Errorf("写入部署事件失败: %w", err)
+	}
+	return nil
+}
+
+// LoadWebhookEvents 读取部署 ID 对应目录下的全部事件，或单个事件 ID 对应的事件
+func (r *deployRepo) LoadWebhookEvents(ctx context.Context, config *biz.NotifyConfig, id string) ([]*biz.WebhookEvent, error) {
+	if !validEventID(id) {
+		return nil, fmt.Errorf("无效的部署事件标识: %s", id)
+	}
+
+	files, err := filepath.Glob(filepath.Join(config.EventDir, id, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		files, err = filepath.Glob(filepath.Join(config.EventDir, "*", "*-"+id+".json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	var events []*biz.WebhookEvent
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", file, err)
+		}
+		var payload webhookPayload
+		if err := json.Unmarshal(content, &payload); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", file, err)
+		}
+		events = append(events, payload.event())
+	}
+	return events, nil
+}
+
+// validEventID 校验标识可以安全地用作文件名
+func validEventID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"context"
+	"net/http"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// weComRetryableCodes 可重试的企业微信错误码：系统繁忙、调用频率超限
+var weComRetryableCodes = map[int]bool{-1: true, 45009: true}
+
+func init() {
+	RegisterNotifier(biz.NotifierWeCom, newWeComNotifier)
+}
+
+// weComNotifier 企业微信群机器人
+type weComNotifier struct {
+	config *biz.NotifierConfig
+	client *http.Client
+}
+
+func newWeComNotifier(config *biz.NotifierConfig, client *http.Client) (Notifier, error) {
+	return &weComNotifier{config: config, client: client}, nil
+}
+
+// Send 发送消息，富文本格式使用 markdown
+func (n *weComNotifier) Send(ctx context.Context, message *biz.Message) error {
+	payload := map[string]interface{}{
+		"msgtype": "text",
+		"text": map[string]string{
+			"content": plainText(message),
+		},
+	}
+	if n.config.Format == biz.MessageRich {
+		payload = map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": markdownText(message, true),
+			},
+		}
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(ctx, n.client, n.config.WebhookURL, payload, &result); err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return &providerError{Provider: "企业微信", Code: result.ErrCode, Message: result.ErrMsg, Retryable: weComRetryableCodes[result.ErrCode]}
+	}
+	return nil
+}
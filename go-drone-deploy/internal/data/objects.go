@@ -0,0 +1,94 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// buildObjects 构建本工具管理的全部 Kubernetes 对象，包括额外清单，按依赖顺序排列
+func (r *deployRepo) buildObjects(ctx context.Context, config *biz.K8sConfig) ([]*unstructured.Unstructured, error) {
+	result, err := r.buildResources(config)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := r.loadManifests(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, manifests...)
+	sortObjects(result)
+
+	return result, nil
+}
+
+// buildResources 构建由配置生成的 Deployment、Service、HPA 及 PDB，部署时以创建或整体更新应用，不含额外清单
+func (r *deployRepo) buildResources(config *biz.K8sConfig) ([]*unstructured.Unstructured, error) {
+	deployment, err := r.buildDeployment(config)
+	if err != nil {
+		return nil, err
+	}
+	objects := []runtime.Object{deployment, r.buildService(config)}
+
+	if config.Autoscaling != nil {
+		hpa, err := r.buildAutoscaler(config)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, hpa)
+	}
+
+	if config.Disruption != nil {
+		pdb, err := r.buildPodDisruptionBudget(config)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, pdb)
+	}
+
+	var result []*unstructured.Unstructured
+	for _, obj := range objects {
+		u, err := toUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// toUnstructured 将类型化对象转换为带 apiVersion/kind 的非结构化对象
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return nil, fmt.Errorf("无法识别对象类型 %T: %v", obj, err)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("转换对象 %T 失败: %w", obj, err)
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvks[0])
+	// 去除构建对象时不会设置的字段
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "spec", "template", "metadata", "creationTimestamp")
+
+	return u, nil
+}
+
+// objectID 返回对象的可读标识，如 Deployment/default/app
+func objectID(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
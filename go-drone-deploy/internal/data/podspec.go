@@ -0,0 +1,204 @@
+package data
+
+import (
+	"sort"
+
+	"go-drone-deploy/internal/biz"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// defaultMaxSurge 默认滚动更新最大超量
+	defaultMaxSurge = "25%"
+	// defaultMaxUnavailable 默认滚动更新最大不可用数
+	defaultMaxUnavailable = "0"
+	// hostnameTopologyKey 按节点打散时使用的拓扑键
+	hostnameTopologyKey = "kubernetes.io/hostname"
+)
+
+// buildStrategy 构建滚动更新策略
+func buildStrategy(config *biz.RolloutStrategy) appsv1.DeploymentStrategy {
+	maxSurge := intstr.Parse(defaultMaxSurge)
+	maxUnavailable := intstr.Parse(defaultMaxUnavailable)
+	if config != nil {
+		if config.MaxSurge != "" {
+			maxSurge = intstr.Parse(config.MaxSurge)
+		}
+		if config.MaxUnavailable != "" {
+			maxUnavailable = intstr.Parse(config.MaxUnavailable)
+		}
+	}
+
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+// buildEnvVars 构建环境变量
+func buildEnvVars(envs []*biz.EnvVar) []corev1.EnvVar {
+	var result []corev1.EnvVar
+	for _, env := range envs {
+		result = append(result, corev1.EnvVar{
+			Name:  env.Name,
+			Value: env.Value,
+		})
+	}
+	return result
+}
+
+// buildTolerations 构建污点容忍
+func buildTolerations(tolerations []*biz.Toleration) []corev1.Toleration {
+	var result []corev1.Toleration
+	for _, t := range tolerations {
+		result = append(result, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	return result
+}
+
+// buildAffinity 构建亲和性，selector 为当前应用 Pod 的标签
+func buildAffinity(config *biz.Affinity, selector map[string]string) *corev1.Affinity {
+	if config == nil {
+		return nil
+	}
+
+	affinity := &corev1.Affinity{}
+
+	if len(config.RequiredNodeLabels) > 0 {
+		// 按 key 排序，保证生成结果稳定
+		keys := make([]string, 0, len(config.RequiredNodeLabels))
+		for key := range config.RequiredNodeLabels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var requirements []corev1.NodeSelectorRequirement
+		for _, key := range keys {
+			requirements = append(requirements, corev1.NodeSelectorRequirement{
+				Key:      key,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   config.RequiredNodeLabels[key],
+			})
+		}
+		affinity.NodeAffinity = &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: requirements},
+				},
+			},
+		}
+	}
+
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: selector},
+		TopologyKey:   hostnameTopologyKey,
+	}
+	switch config.PodAntiAffinity {
+	case "required":
+		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term},
+		}
+	case "preferred":
+		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: term},
+			},
+		}
+	}
+
+	return affinity
+}
+
+// buildTopologySpread 构建拓扑分布约束
+func buildTopologySpread(constraints []*biz.TopologySpread, selector map[string]string) []corev1.TopologySpreadConstraint {
+	var result []corev1.TopologySpreadConstraint
+	for _, c := range constraints {
+		maxSkew := c.MaxSkew
+		if maxSkew <= 0 {
+			maxSkew = 1
+		}
+		whenUnsatisfiable := corev1.ScheduleAnyway
+		if c.WhenUnsatisfiable != "" {
+			whenUnsatisfiable = corev1.UnsatisfiableConstraintAction(c.WhenUnsatisfiable)
+		}
+		result = append(result, corev1.TopologySpreadConstraint{
+			MaxSkew:           maxSkew,
+			TopologyKey:       c.TopologyKey,
+			WhenUnsatisfiable: whenUnsatisfiable,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: selector},
+		})
+	}
+	return result
+}
+
+// buildImagePullSecrets 构建镜像拉取凭证引用
+func buildImagePullSecrets(names []string) []corev1.LocalObjectReference {
+	var result []corev1.LocalObjectReference
+	for _, name := range names {
+		result = append(result, corev1.LocalObjectReference{Name: name})
+	}
+	return result
+}
+
+// buildPodSecurityContext 构建 Pod 级安全上下文，默认要求以非 root 用户运行，满足 restricted 级别的 Pod 安全标准
+func buildPodSecurityContext(config *biz.SecurityContext) *corev1.PodSecurityContext {
+	seccomp := corev1.SeccompProfileTypeRuntimeDefault
+	runAsNonRoot := true
+	podSC := &corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}
+	if config != nil {
+		if config.RunAsNonRoot != nil {
+			podSC.RunAsNonRoot = config.RunAsNonRoot
+		}
+		podSC.RunAsUser = config.RunAsUser
+		podSC.RunAsGroup = config.RunAsGroup
+		podSC.FSGroup = config.FSGroup
+		if config.SeccompProfile != "" {
+			seccomp = corev1.SeccompProfileType(config.SeccompProfile)
+		}
+	}
+	podSC.SeccompProfile = &corev1.SeccompProfile{Type: seccomp}
+	return podSC
+}
+
+// buildContainerSecurityContext 构建容器级安全上下文
+func buildContainerSecurityContext(config *biz.SecurityContext) *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	drop := []corev1.Capability{"ALL"}
+	var add []corev1.Capability
+	sc := &corev1.SecurityContext{}
+	if config != nil {
+		sc.ReadOnlyRootFilesystem = config.ReadOnlyRootFilesystem
+		if config.AllowPrivilegeEscalation != nil {
+			allowPrivilegeEscalation = *config.AllowPrivilegeEscalation
+		}
+		if len(config.DropCapabilities) > 0 {
+			drop = toCapabilities(config.DropCapabilities)
+		}
+		add = toCapabilities(config.AddCapabilities)
+	}
+	sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	sc.Capabilities = &corev1.Capabilities{Drop: drop, Add: add}
+	return sc
+}
+
+// toCapabilities 转换 capabilities 列表
+func toCapabilities(names []string) []corev1.Capability {
+	var result []corev1.Capability
+	for _, name := range names {
+		result = append(result, corev1.Capability(name))
+	}
+	return result
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+)
+
+// TestBuildPodSecurityContext 默认以非 root 用户运行，显式设置时使用配置的值
+func TestBuildPodSecurityContext(t *testing.T) {
+	allowRoot := false
+	tests := []struct {
+		name   string
+		config *biz.SecurityContext
+		want   bool
+	}{
+		{name: "未配置", config: nil, want: true},
+		{name: "未设置 run_as_non_root", config: &biz.SecurityContext{SeccompProfile: "Localhost"}, want: true},
+		{name: "允许 root", config: &biz.SecurityContext{RunAsNonRoot: &allowRoot}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := buildPodSecurityContext(tt.config)
+			if sc.RunAsNonRoot == nil || *sc.RunAsNonRoot != tt.want {
+				t.Errorf("RunAsNonRoot = %v, want %v", sc.RunAsNonRoot, tt.want)
+			}
+		})
+	}
+}
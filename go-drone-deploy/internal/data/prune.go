@@ -0,0 +1,233 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go-drone-deploy/internal/biz"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// inventoryKey 清单 ConfigMap 中保存对象列表的键
+const inventoryKey = "objects"
+
+// inventoryEntry 清单中记录的对象
+type inventoryEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// inventoryID 标识清单中的对象，不含版本，apiVersion 升级（如 autoscaling/v2beta2 → autoscaling/v2）后仍是同一对象
+type inventoryID struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// PruneK8s 对比上次部署的清单，删除本次配置中已不存在的托管对象并更新清单
+func (r *deployRepo) PruneK8s(ctx context.Context, config *biz.K8sConfig, dryRun bool) ([]*biz.ResourceRef, error) {
+	r.log.WithContext(ctx).Infof("清理 Kubernetes 资源: %s", releaseName(config))
+
+	// 创建 Kubernetes 动态客户端
+	dynamicClient, mapper, err := r.createDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	desired, err := r.buildObjects(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, kept, err := r.pruneCandidates(ctx, dynamicClient, mapper, config, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []*biz.ResourceRef
+	for _, obj := range candidates {
+		ref := &biz.ResourceRef{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+		pruned = append(pruned, ref)
+		if dryRun {
+			r.log.WithContext(ctx).Infof("[dry-run] 将清理 %s", objectID(obj))
+			continue
+		}
+
+		ri, err := resourceInterface(dynamicClient, mapper, obj)
+		if err != nil {
+			return nil, err
+		}
+		propagation := metav1.DeletePropagationBackground
+		err = ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("清理 %s 失败: %w", objectID(obj), err)
+		}
+		r.log.WithContext(ctx).Infof("%s 已清理", objectID(obj))
+	}
+
+	if !dryRun {
+		entries := kept
+		for _, obj := range desired {
+			entries = append(entries, newInventoryEntry(obj))
+		}
+		if err := r.writeInventory(ctx, dynamicClient, mapper, config, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// pruneCandidates 返回需要清理的现存对象，以及因受保护而保留在清单中的条目
+func (r *deployRepo) pruneCandidates(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper,
+	config *biz.K8sConfig, desired []*unstructured.Unstructured) ([]*unstructured.Unstructured, []inventoryEntry, error) {
+	previous, err := r.readInventory(ctx, client, mapper, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wanted := make(map[inventoryID]bool, len(desired))
+	for _, obj := range desired {
+		wanted[newInventoryEntry(obj).id()] = true
+	}
+
+	var candidates []*unstructured.Unstructured
+	var kept []inventoryEntry
+	for _, entry := range previous {
+		if wanted[entry.id()] {
+			continue
+		}
+
+		obj := entry.object()
+		ri, err := resourceInterface(client, mapper, obj)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				// 资源类型已不存在（如 CRD 已删除），对象随之消失
+				continue
+			}
+			return nil, nil, err
+		}
+		live, err := ri.Get(ctx, entry.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("获取 %s 失败: %w", objectID(obj), err)
+		}
+
+		// 只清理仍归属于本工具、且未被保护的对象
+		if live.GetLabels()[labelManagedBy] != managedByValue {
+			r.log.WithContext(ctx).Warnf("%s 已不再由 %s 管理，跳过清理", objectID(obj), managedByValue)
+			continue
+		}
+		if live.GetAnnotations()[pruneProtectionAnnotation] == "true" {
+			r.log.WithContext(ctx).Infof("%s 设置了 %s，跳过清理", objectID(obj), pruneProtectionAnnotation)
+			kept = append(kept, entry)
+			continue
+		}
+		candidates = append(candidates, live)
+	}
+
+	// 按依赖的逆序清理：先普通资源，最后 CRD 和 Namespace
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return kindRank(candidates[i]) > kindRank(candidates[j])
+	})
+
+	return candidates, kept, nil
+}
+
+// readInventory 读取上次部署记录的对象清单，不存在时返回空
+func (r *deployRepo) readInventory(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, config *biz.K8sConfig) ([]inventoryEntry, error) {
+	inventory := newInventory(config, "")
+	ri, err := resourceInterface(client, mapper, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := ri.Get(ctx, inventory.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取部署清单失败: %w", err)
+	}
+
+	content, _, _ := unstructured.NestedString(live.Object, "data", inventoryKey)
+	if content == "" {
+		return nil, nil
+	}
+	var entries []inventoryEntry
+	if err := json.Unmarshal([]byte(content), &entries); err != nil {
+		return nil, fmt.Errorf("解析部署清单失败: %w", err)
+	}
+	return entries, nil
+}
+
+// writeInventory 记录本次部署写入的对象
+func (r *deployRepo) writeInventory(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, config *biz.K8sConfig, entries []inventoryEntry) error {
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("序列化部署清单失败: %w", err)
+	}
+
+	if err := applyObject(ctx, client, mapper, newInventory(config, string(content))); err != nil {
+		return fmt.Errorf("写入部署清单失败: %w", err)
+	}
+	return nil
+}
+
+// newInventory 构建保存对象清单的 ConfigMap
+func newInventory(config *biz.K8sConfig, content string) *unstructured.Unstructured {
+	inventory := &unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{
+			inventoryKey: content,
+		},
+	}}
+	inventory.SetAPIVersion("v1")
+	inventory.SetKind("ConfigMap")
+	inventory.SetNamespace(config.Namespace)
+	inventory.SetName(releaseName(config) + "-inventory")
+	inventory.SetLabels(ownershipLabels(config))
+	return inventory
+}
+
+// newInventoryEntry 根据对象生成清单条目
+func newInventoryEntry(obj *unstructured.Unstructured) inventoryEntry {
+	return inventoryEntry{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// id 返回不含版本的对象标识
+func (e inventoryEntry) id() inventoryID {
+	return inventoryID{
+		Group:     schema.FromAPIVersionAndKind(e.APIVersion, e.Kind).Group,
+		Kind:      e.Kind,
+		Namespace: e.Namespace,
+		Name:      e.Name,
+	}
+}
+
+// object 生成仅包含类型与名称的对象，用于定位资源
+func (e inventoryEntry) object() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion(e.APIVersion)
+	obj.SetKind(e.Kind)
+	obj.SetNamespace(e.Namespace)
+	obj.SetName(e.Name)
+	return obj
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	hpaV2beta2GVR = schema.GroupVersionResource{Group: "autoscaling", Version: "v2beta2", Resource: "horizontalpodautoscalers"}
+)
+
+func pruneTestConfig() *biz.K8sConfig {
+	return &biz.K8sConfig{
+		Namespace:      "default",
+		DeploymentName: "demo",
+		ServiceName:    "demo",
+		Image:          "registry/demo:v1",
+		Replicas:       1,
+		Autoscaling:    &biz.Autoscaling{MinReplicas: 1, MaxReplicas: 3, TargetCPUUtilizationPercentage: 80},
+	}
+}
+
+// inventoryWith 生成记录了指定条目的部署清单
+func inventoryWith(t *testing.T, config *biz.K8sConfig, entries ...inventoryEntry) *unstructured.Unstructured {
+	t.Helper()
+	content, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newInventory(config, string(content))
+}
+
+func TestPruneK8s(t *testing.T) {
+	config := pruneTestConfig()
+	managed := ownershipLabels(config)
+
+	removed := unstructuredObject("v1", "ConfigMap", "default", "removed", managed)
+	unmanaged := unstructuredObject("v1", "ConfigMap", "default", "unmanaged", nil)
+	inventory := inventoryWith(t, config,
+		inventoryEntry{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "demo"},
+		inventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "removed"},
+		inventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "unmanaged"},
+	)
+	repo, k := newTestRepo(t, nil, inventory, removed, unmanaged)
+
+	pruned, err := repo.PruneK8s(context.Background(), config, true)
+	if err != nil {
+		t.Fatalf("PruneK8s(dry-run) error = %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].Name != "removed" {
+		t.Fatalf("dry-run pruned = %v, want [removed]", pruned)
+	}
+	if k.get(t, configMapsGVR, "default", "removed") == nil {
+		t.Fatal("dry-run 删除了对象")
+	}
+
+	if _, err := repo.PruneK8s(context.Background(), config, false); err != nil {
+		t.Fatalf("PruneK8s() error = %v", err)
+	}
+	if k.get(t, configMapsGVR, "default", "removed") != nil {
+		t.Error("已移除的托管对象未被清理")
+	}
+	if k.get(t, configMapsGVR, "default", "unmanaged") == nil {
+		t.Error("不再由本工具管理的对象被清理")
+	}
+
+	entries, err := repo.readInventory(context.Background(), k.dynamic, k.mapper, config)
+	if err != nil {
+		t.Fatalf("readInventory() error = %v", err)
+	}
+	for _, e := range entries {
+		if e.Name == "removed" || e.Name == "unmanaged" {
+			t.Errorf("清单仍包含 %s", e.Name)
+		}
+	}
+}
+
+// TestPruneK8sAPIVersionBump 对象的 apiVersion 升级后不应被当作已移除的对象清理
+func TestPruneK8sAPIVersionBump(t *testing.T) {
+	config := pruneTestConfig()
+
+	// 上次部署以 autoscaling/v2beta2 记录了 HPA
+	live := unstructuredObject("autoscaling/v2beta2", "HorizontalPodAutoscaler", "default", "demo", ownershipLabels(config))
+	inventory := inventoryWith(t, config,
+		inventoryEntry{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Namespace: "default", Name: "demo"},
+	)
+	repo, k := newTestRepo(t, nil, inventory, live)
+
+	pruned, err := repo.PruneK8s(context.Background(), config, false)
+	if err != nil {
+		t.Fatalf("PruneK8s() error = %v", err)
+	}
+	if len(pruned) != 0 {
+		t.Fatalf("pruned = %v, want none", pruned)
+	}
+	if k.get(t, hpaV2beta2GVR, "default", "demo") == nil {
+		t.Fatal("升级 apiVersion 的 HPA 被清理")
+	}
+
+	entries, err := repo.readInventory(context.Background(), k.dynamic, k.mapper, config)
+	if err != nil {
+		t.Fatalf("readInventory() error = %v", err)
+	}
+	var found bool
+	for _, e := range entries {
+		if e.Kind == "HorizontalPodAutoscaler" {
+			found = true
+			if e.APIVersion != "autoscaling/v2" {
+				t.Errorf("清单中 HPA 的 apiVersion = %s, want autoscaling/v2", e.APIVersion)
+			}
+		}
+	}
+	if !found {
+		t.Error("清单中缺少 HPA")
+	}
+}
+
+func TestInventoryID(t *testing.T) {
+	tests := []struct {
+		a, b inventoryEntry
+		same bool
+	}{
+		{
+			a:    inventoryEntry{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Namespace: "ns", Name: "x"},
+			b:    inventoryEntry{APIVersion: "policy/v1", Kind: "PodDisruptionBudget", Namespace: "ns", Name: "x"},
+			same: true,
+		},
+		{
+			a:    inventoryEntry{APIVersion: "v1", Kind: "Service", Namespace: "ns", Name: "x"},
+			b:    inventoryEntry{APIVersion: "serving.knative.dev/v1", Kind: "Service", Namespace: "ns", Name: "x"},
+			same: false,
+		},
+		{
+			a:    inventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "a", Name: "x"},
+			b:    inventoryEntry{APIVersion: "v1", Kind: "ConfigMap", Namespace: "b", Name: "x"},
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.a.id() == tt.b.id(); got != tt.same {
+			t.Errorf("%v == %v: got %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
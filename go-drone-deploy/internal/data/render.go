@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"go-drone-deploy/internal/biz"
+
+	"sigs.k8s.io/yaml"
+)
+
+// RenderK8s 渲染全部 Kubernetes 资源为 YAML，不访问集群；
+// 包含命名空间初始化的对象，镜像拉取 Secret 含仓库凭证，不输出
+func (r *deployRepo) RenderK8s(ctx context.Context, config *biz.K8sConfig) ([]*biz.Manifest, error) {
+	r.log.WithContext(ctx).Infof("渲染 Kubernetes 资源: %s", config.DeploymentName)
+
+	objects, err := r.buildObjects(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Bootstrap != nil {
+		bootstrap, err := r.buildBootstrapObjects(config)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(bootstrap, objects...)
+		sortObjects(objects)
+		if config.Bootstrap.ImagePullSecret != "" {
+			r.log.WithContext(ctx).Warnf("镜像拉取 Secret %s 包含仓库凭证，未包含在渲染结果中", config.Bootstrap.ImagePullSecret)
+		}
+	}
+
+	var manifests []*biz.Manifest
+	for _, obj := range objects {
+		content, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("序列化 %s 失败: %w", objectID(obj), err)
+		}
+		manifests = append(manifests, &biz.Manifest{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			Content:    content,
+		})
+	}
+
+	return manifests, nil
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-drone-deploy/internal/biz"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+// TestRenderK8sGolden 渲染结果与 golden 文件一致，且多次渲染的输出相同
+func TestRenderK8sGolden(t *testing.T) {
+	config := &biz.K8sConfig{
+		Namespace:      "default",
+		DeploymentName: "demo",
+		ServiceName:    "demo",
+		AppName:        "demo",
+		Image:          "registry/demo:v1.2.0",
+		Version:        "v1.2.0",
+		Replicas:       2,
+		Ports:          []*biz.Port{{Name: "http", Port: 80, TargetPort: 8080}},
+		Autoscaling:    &biz.Autoscaling{MinReplicas: 2, MaxReplicas: 5, TargetCPUUtilizationPercentage: 80},
+		DeployMeta:     &biz.DeployMeta{Commit: "abc123", Author: "dev"},
+	}
+	repo, _ := newTestRepo(t, nil)
+
+	render := func() []byte {
+		manifests, err := repo.RenderK8s(context.Background(), config)
+		if err != nil {
+			t.Fatalf("RenderK8s() error = %v", err)
+		}
+		var b bytes.Buffer
+		for i, m := range manifests {
+			if i > 0 {
+				b.WriteString("---\n")
+			}
+			b.Write(m.Content)
+		}
+		return b.Bytes()
+	}
+
+	got := render()
+	if again := render(); !bytes.Equal(got, again) {
+		t.Fatal("两次渲染的结果不同")
+	}
+
+	golden := filepath.Join("testdata", "render.golden.yaml")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("读取 golden 文件失败: %v（使用 -update 生成）", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("渲染结果与 %s 不一致（使用 -update 更新）:\n%s", golden, got)
+	}
+}
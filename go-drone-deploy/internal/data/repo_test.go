@@ -0,0 +1,116 @@
+package data
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testMapper 测试使用的固定类型映射
+type testMapper struct {
+	*meta.DefaultRESTMapper
+}
+
+func (testMapper) Reset() {}
+
+func newTestMapper() testMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range []schema.GroupVersionKind{
+		{Version: "v1", Kind: "ConfigMap"},
+		{Version: "v1", Kind: "Secret"},
+		{Version: "v1", Kind: "Service"},
+		{Version: "v1", Kind: "ResourceQuota"},
+		{Version: "v1", Kind: "LimitRange"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"},
+		{Group: "autoscaling", Version: "v2beta2", Kind: "HorizontalPodAutoscaler"},
+		{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"},
+		{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"},
+	} {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	return testMapper{mapper}
+}
+
+// testK8s 测试使用的假集群，类型化客户端与动态客户端的存储相互独立
+type testK8s struct {
+	clientset *k8sfake.Clientset
+	dynamic   *dynamicfake.FakeDynamicClient
+	mapper    testMapper
+}
+
+// newTestRepo 创建使用假集群的部署仓库，typed 预置到类型化客户端，objects 预置到动态客户端
+func newTestRepo(t *testing.T, typed []runtime.Object, objects ...runtime.Object) (*deployRepo, *testK8s) {
+	t.Helper()
+	k := &testK8s{
+		clientset: k8sfake.NewSimpleClientset(typed...),
+		dynamic:   dynamicfake.NewSimpleDynamicClient(scheme.Scheme, objects...),
+		mapper:    newTestMapper(),
+	}
+	// 假动态客户端不支持对不存在的对象执行服务端应用，按创建或整体替换处理
+	k.dynamic.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, err
+		}
+		tracker := k.dynamic.Tracker()
+		gvr, ns := patch.GetResource(), patch.GetNamespace()
+		if _, err := tracker.Get(gvr, ns, patch.GetName()); apierrors.IsNotFound(err) {
+			return true, obj, tracker.Create(gvr, obj, ns)
+		}
+		return true, obj, tracker.Update(gvr, obj, ns)
+	})
+
+	d, _, err := NewData(nil, log.NewStdLogger(io.Discard), WithK8sClients(k.clientset, k.dynamic, k.mapper))
+	if err != nil {
+		t.Fatalf("NewData() error = %v", err)
+	}
+	return NewDeployRepo(d, log.NewStdLogger(io.Discard)).(*deployRepo), k
+}
+
+// get 从动态客户端读取对象，不存在时返回 nil
+func (k *testK8s) get(t *testing.T, gvr schema.GroupVersionResource, namespace, name string) *unstructured.Unstructured {
+	t.Helper()
+	obj, err := k.dynamic.Tracker().Get(gvr, namespace, name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("获取 %s %s/%s 失败: %v", gvr.Resource, namespace, name, err)
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		t.Fatalf("%s %s/%s 不是非结构化对象: %T", gvr.Resource, namespace, name, obj)
+	}
+	return u
+}
+
+// unstructuredObject 生成测试用的非结构化对象
+func unstructuredObject(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	obj.SetCreationTimestamp(metav1.Now())
+	return obj
+}
@@ -0,0 +1,350 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultRolloutTimeout 等待发布完成的默认超时时间
+	defaultRolloutTimeout = 5 * time.Minute
+	// rolloutPollInterval 轮询发布状态的间隔
+	rolloutPollInterval = 2 * time.Second
+	// defaultDiagnosticLogLines 每个异常容器默认收集的日志行数
+	defaultDiagnosticLogLines int64 = 50
+	// maxDiagnosticEvents 最多收集的事件数
+	maxDiagnosticEvents = 20
+	// maxDiagnosticContainers 最多收集的异常容器数
+	maxDiagnosticContainers = 10
+	// revisionAnnotation Deployment 及其 ReplicaSet 上记录的版本号
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+	// restartedAtAnnotation 触发滚动重启的 Pod 模板注解
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// failedWaitingReasons 表示容器无法正常运行的等待原因
+var failedWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// WaitK8sRollout 等待 Deployment 滚动更新完成，判定逻辑与 kubectl rollout status 一致
+func (r *deployRepo) WaitK8sRollout(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Infof("等待 Kubernetes Deployment 发布: %s", config.DeploymentName)
+
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	timeout := config.RolloutTimeout
+	if timeout <= 0 {
+		timeout = defaultRolloutTimeout
+	}
+
+	deploymentsClient := clientset.AppsV1().Deployments(config.Namespace)
+	var progressErr error
+	err = wait.PollUntilContextTimeout(ctx, rolloutPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := deploymentsClient.Get(ctx, config.DeploymentName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		done, err := rolloutComplete(deployment)
+		if err != nil {
+			progressErr = err
+			return false, err
+		}
+		return done, nil
+	})
+	if progressErr != nil {
+		return progressErr
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			return fmt.Errorf("Deployment %s 在 %s 内未完成发布", config.DeploymentName, timeout)
+		}
+		return fmt.Errorf("等待 Deployment %s 发布被取消: %w", config.DeploymentName, ctx.Err())
+	}
+
+	r.log.WithContext(ctx).Info("Kubernetes Deployment 发布完成")
+	return nil
+}
+
+// rolloutComplete 判断 Deployment 是否已完成发布
+func rolloutComplete(deployment *appsv1.Deployment) (bool, error) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, nil
+	}
+
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, fmt.Errorf("Deployment %s 超过发布期限: %s", deployment.Name, c.Message)
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	if status.UpdatedReplicas < replicas {
+		return false, nil
+	}
+	if status.Replicas > status.UpdatedReplicas {
+		return false, nil
+	}
+	if status.AvailableReplicas < status.UpdatedReplicas {
+		return false, nil
+	}
+	return true, nil
+}
+
+// CollectK8sDiagnostics 收集 Deployment 相关的事件、异常容器状态及日志
+func (r *deployRepo) CollectK8sDiagnostics(ctx context.Context, config *biz.K8sConfig) (*biz.Diagnostics, error) {
+	r.log.WithContext(ctx).Infof("收集 Kubernetes 诊断信息: %s", config.DeploymentName)
+
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	// 关注的对象：Deployment、其 ReplicaSet 与 Pod，以及钩子 Job 的 Pod
+	involved := map[string]bool{config.DeploymentName: true}
+	appSelector := labels.SelectorFromSet(selectorLabels(config)).String()
+	hookSelector := fmt.Sprintf("%s=%s,%s", labelInstance, releaseName(config), labelHook)
+
+	replicaSets, err := clientset.AppsV1().ReplicaSets(config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: appSelector})
+	if err != nil {
+		return nil, fmt.Errorf("获取 ReplicaSet 失败: %w", err)
+	}
+	for _, rs := range replicaSets.Items {
+		involved[rs.Name] = true
+	}
+
+	var pods []corev1.Pod
+	for _, selector := range []string{appSelector, hookSelector} {
+		list, err := clientset.CoreV1().Pods(config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, fmt.Errorf("获取 Pod 失败: %w", err)
+		}
+		pods = append(pods, list.Items...)
+	}
+
+	tailLines := config.DiagnosticLogLines
+	if tailLines <= 0 {
+		tailLines = defaultDiagnosticLogLines
+	}
+
+	diagnostics := &biz.Diagnostics{}
+	for _, pod := range pods {
+		involved[pod.Name] = true
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if len(diagnostics.Containers) >= maxDiagnosticContainers {
+				break
+			}
+			diagnosis := diagnoseContainer(pod.Name, cs)
+			if diagnosis == nil {
+				continue
+			}
+			diagnosis.Logs = r.containerLogs(ctx, clientset, &pod, cs, tailLines)
+			diagnostics.Containers = append(diagnostics.Containers, diagnosis)
+		}
+	}
+
+	events, err := clientset.CoreV1().Events(config.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取事件失败: %w", err)
+	}
+	for _, e := range events.Items {
+		if !involved[e.InvolvedObject.Name] {
+			continue
+		}
+		diagnostics.Events = append(diagnostics.Events, &biz.DiagnosticEvent{
+			Time:    eventTime(&e),
+			Type:    e.Type,
+			Object:  fmt.Sprintf("%s/%s", e.InvolvedObject.Kind, e.InvolvedObject.Name),
+			Reason:  e.Reason,
+			Message: strings.TrimSpace(e.Message),
+		})
+	}
+	sort.SliceStable(diagnostics.Events, func(i, j int) bool {
+		return diagnostics.Events[i].Time.Before(diagnostics.Events[j].Time)
+	})
+	if len(diagnostics.Events) > maxDiagnosticEvents {
+		diagnostics.Events = diagnostics.Events[len(diagnostics.Events)-maxDiagnosticEvents:]
+	}
+
+	return diagnostics, nil
+}
+
+// diagnoseContainer 判断容器是否异常，正常时返回 nil
+func diagnoseContainer(podName string, cs corev1.ContainerStatus) *biz.ContainerDiagnosis {
+	diagnosis := &biz.ContainerDiagnosis{
+		Pod:          podName,
+		Container:    cs.Name,
+		RestartCount: cs.RestartCount,
+	}
+
+	last := cs.LastTerminationState.Terminated
+	switch {
+	case cs.State.Waiting != nil && failedWaitingReasons[cs.State.Waiting.Reason]:
+		diagnosis.Reason = cs.State.Waiting.Reason
+		diagnosis.Message = cs.State.Waiting.Message
+		// CrashLoopBackOff 时补充上一次退出的原因，如 OOMKilled
+		if last != nil {
+			diagnosis.Reason = fmt.Sprintf("%s (%s)", diagnosis.Reason, last.Reason)
+			diagnosis.ExitCode = last.ExitCode
+		}
+	case cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0:
+		diagnosis.Reason = cs.State.Terminated.Reason
+		diagnosis.Message = cs.State.Terminated.Message
+		diagnosis.ExitCode = cs.State.Terminated.ExitCode
+	case last != nil && last.Reason == "OOMKilled":
+		diagnosis.Reason = last.Reason
+		diagnosis.ExitCode = last.ExitCode
+	default:
+		return nil
+	}
+
+	return diagnosis
+}
+
+// containerLogs 获取容器最后若干行日志，容器未运行过时返回空
+func (r *deployRepo) containerLogs(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, cs corev1.ContainerStatus, tailLines int64) string {
+	options := &corev1.PodLogOptions{Container: cs.Name, TailLines: &tailLines}
+	switch {
+	case cs.State.Terminated != nil, cs.State.Running != nil:
+	case cs.LastTerminationState.Terminated != nil:
+		options.Previous = true
+	default:
+		return ""
+	}
+
+	stream, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, options).Stream(ctx)
+	if err != nil {
+		r.log.WithContext(ctx).Warnf("获取 %s/%s 日志失败: %v", pod.Name, cs.Name, err)
+		return ""
+	}
+	defer stream.Close()
+
+	content, err := io.ReadAll(stream)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// eventTime 返回事件最近一次发生的时间
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.FirstTimestamp.Time
+	}
+}
+
+// GetK8sRevision 返回 Deployment 当前的版本号，Deployment 不存在或尚未分配版本时返回 0
+func (r *deployRepo) GetK8sRevision(ctx context.Context, config *biz.K8sConfig) (int64, error) {
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return 0, fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	deployment, err := clientset.AppsV1().Deployments(config.Namespace).Get(ctx, config.DeploymentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("获取 Deployment 失败: %w", err)
+	}
+	value := deployment.Annotations[revisionAnnotation]
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析 Deployment 版本号失败: %w", err)
+	}
+	return revision, nil
+}
+
+// RollbackK8sDeployment 将 Deployment 回滚到上一个版本，做法与 kubectl rollout undo 一致
+func (r *deployRepo) RollbackK8sDeployment(ctx context.Context, config *biz.K8sConfig) error {
+	r.log.WithContext(ctx).Infof("回滚 Kubernetes Deployment: %s", config.DeploymentName)
+
+	// 创建 Kubernetes 客户端
+	clientset, err := r.createK8sClient(config)
+	if err != nil {
+		return fmt.Errorf("创建 Kubernetes 客户端失败: %w", err)
+	}
+
+	deploymentsClient := clientset.AppsV1().Deployments(config.Namespace)
+	deployment, err := deploymentsClient.Get(ctx, config.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("获取 Deployment 失败: %w", err)
+	}
+	current, _ := strconv.ParseInt(deployment.Annotations[revisionAnnotation], 10, 64)
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("解析 Deployment 选择器失败: %w", err)
+	}
+	replicaSets, err := clientset.AppsV1().ReplicaSets(config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return fmt.Errorf("获取 ReplicaSet 失败: %w", err)
+	}
+
+	// 查找版本号低于当前版本的最新 ReplicaSet
+	var previous *appsv1.ReplicaSet
+	var previousRevision int64
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		revision, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil || revision >= current || revision <= previousRevision {
+			continue
+		}
+		previous, previousRevision = rs, revision
+	}
+	if previous == nil {
+		return fmt.Errorf("Deployment %s 没有可回滚的历史版本", config.DeploymentName)
+	}
+
+	template := previous.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	deployment.Spec.Template = *template
+	if _, err := deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("回滚 Deployment 失败: %w", err)
+	}
+
+	r.log.WithContext(ctx).Infof("Kubernetes Deployment 已回滚到版本 %d", previousRevision)
+	return nil
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-drone-deploy/internal/biz"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func rolloutTestConfig() *biz.K8sConfig {
+	return &biz.K8sConfig{
+		Namespace:      "default",
+		DeploymentName: "demo",
+		ServiceName:    "demo",
+		Image:          "registry/demo:v1",
+		Version:        "v1",
+		Replicas:       2,
+		Ports:          []*biz.Port{{Name: "http", Port: 80, TargetPort: 8080}},
+		RolloutTimeout: time.Second,
+	}
+}
+
+// setRolloutStatus 模拟 Deployment 控制器更新发布状态
+func setRolloutStatus(t *testing.T, k *testK8s, status appsv1.DeploymentStatus) {
+	t.Helper()
+	deployments := k.clientset.AppsV1().Deployments("default")
+	deployment, err := deployments.Get(context.Background(), "demo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment.Status = status
+	if _, err := deployments.UpdateStatus(context.Background(), deployment, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestApplyK8sDeploymentRollout 创建 Deployment 与 Service 并等待发布完成；再次部署时更新镜像，HPA 管理的副本数保持不变
+func TestApplyK8sDeploymentRollout(t *testing.T) {
+	ctx := context.Background()
+	config := rolloutTestConfig()
+	repo, k := newTestRepo(t, nil)
+
+	if err := repo.ApplyK8sDeployment(ctx, config); err != nil {
+		t.Fatalf("ApplyK8sDeployment() error = %v", err)
+	}
+	if err := repo.ApplyK8sService(ctx, config); err != nil {
+		t.Fatalf("ApplyK8sService() error = %v", err)
+	}
+
+	deployment, err := k.clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Deployment 未创建: %v", err)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "registry/demo:v1" {
+		t.Errorf("镜像 = %s", image)
+	}
+	if *deployment.Spec.Replicas != 2 || deployment.Labels[labelManagedBy] != managedByValue {
+		t.Errorf("Deployment replicas = %d, labels = %v", *deployment.Spec.Replicas, deployment.Labels)
+	}
+	if _, err := k.clientset.CoreV1().Services("default").Get(ctx, "demo", metav1.GetOptions{}); err != nil {
+		t.Fatalf("Service 未创建: %v", err)
+	}
+
+	setRolloutStatus(t, k, appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2})
+	if err := repo.WaitK8sRollout(ctx, config); err != nil {
+		t.Fatalf("WaitK8sRollout() error = %v", err)
+	}
+
+	// 扩缩容后再次部署新版本
+	replicas := int32(5)
+	deployment.Spec.Replicas = &replicas
+	if _, err := k.clientset.AppsV1().Deployments("default").Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	config.Image, config.Version = "registry/demo:v2", "v2"
+	config.Autoscaling = &biz.Autoscaling{MinReplicas: 2, MaxReplicas: 10}
+	if err := repo.ApplyK8sDeployment(ctx, config); err != nil {
+		t.Fatalf("再次 ApplyK8sDeployment() error = %v", err)
+	}
+	deployment, _ = k.clientset.AppsV1().Deployments("default").Get(ctx, "demo", metav1.GetOptions{})
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "registry/demo:v2" {
+		t.Errorf("更新后镜像 = %s", image)
+	}
+	if *deployment.Spec.Replicas != 5 {
+		t.Errorf("更新后 replicas = %d, want 保留 HPA 调整后的 5", *deployment.Spec.Replicas)
+	}
+}
+
+func TestWaitK8sRolloutFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  appsv1.DeploymentStatus
+		wantErr string
+	}{
+		{
+			name: "超过发布期限",
+			status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+				Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded", Message: "副本未就绪",
+			}}},
+			wantErr: "超过发布期限",
+		},
+		{
+			name:    "超时",
+			status:  appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+			wantErr: "未完成发布",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := rolloutTestConfig()
+			config.RolloutTimeout = 50 * time.Millisecond
+			repo, k := newTestRepo(t, nil)
+			if err := repo.ApplyK8sDeployment(context.Background(), config); err != nil {
+				t.Fatal(err)
+			}
+			setRolloutStatus(t, k, tt.status)
+
+			err := repo.WaitK8sRollout(context.Background(), config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("WaitK8sRollout() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRollbackK8sDeployment 回滚到版本号低于当前版本的最新 ReplicaSet
+func TestRollbackK8sDeployment(t *testing.T) {
+	ctx := context.Background()
+	config := rolloutTestConfig()
+	repo, k := newTestRepo(t, nil)
+	if err := repo.ApplyK8sDeployment(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+
+	deployments := k.clientset.AppsV1().Deployments("default")
+	deployment, _ := deployments.Get(ctx, "demo", metav1.GetOptions{})
+	deployment.UID = "demo-uid"
+	deployment.Annotations = map[string]string{revisionAnnotation: "3"}
+	if deployment, _ = deployments.Update(ctx, deployment, metav1.UpdateOptions{}); deployment == nil {
+		t.Fatal("更新 Deployment 失败")
+	}
+
+	revision, err := repo.GetK8sRevision(ctx, config)
+	if err != nil || revision != 3 {
+		t.Fatalf("GetK8sRevision() = %d, %v, want 3", revision, err)
+	}
+
+	for _, rs := range []struct {
+		revision, image string
+	}{{"1", "registry/demo:v0"}, {"2", "registry/demo:v1-previous"}, {"3", "registry/demo:v1"}} {
+		template := deployment.Spec.Template.DeepCopy()
+		template.Spec.Containers[0].Image = rs.image
+		template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "hash-" + rs.revision
+		replicaSet := &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "demo-" + rs.revision,
+				Namespace:       "default",
+				Labels:          template.Labels,
+				Annotations:     map[string]string{revisionAnnotation: rs.revision},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+			},
+			Spec: appsv1.ReplicaSetSpec{Selector: deployment.Spec.Selector, Template: *template},
+		}
+		if _, err := k.clientset.AppsV1().ReplicaSets("default").Create(ctx, replicaSet, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.RollbackK8sDeployment(ctx, config); err != nil {
+		t.Fatalf("RollbackK8sDeployment() error = %v", err)
+	}
+	deployment, _ = deployments.Get(ctx, "demo", metav1.GetOptions{})
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "registry/demo:v1-previous" {
+		t.Errorf("回滚后镜像 = %s, want registry/demo:v1-previous", image)
+	}
+	if _, ok := deployment.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+		t.Error("回滚后的 Pod 模板保留了 pod-template-hash 标签")
+	}
+}
+
+// TestGetK8sRevisionNotFound Deployment 不存在时版本号为 0
+func TestGetK8sRevisionNotFound(t *testing.T) {
+	repo, _ := newTestRepo(t, nil)
+	revision, err := repo.GetK8sRevision(context.Background(), rolloutTestConfig())
+	if err != nil || revision != 0 {
+		t.Errorf("GetK8sRevision() = %d, %v, want 0, nil", revision, err)
+	}
+}